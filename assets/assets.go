@@ -7,7 +7,7 @@ import (
 	"net/http"
 )
 
-//go:embed css/*.min.css data/*.min.json data/site.webmanifest img/*.png img/*.svg js/*.min.js migrations/*.sql *.min.html favicon.ico
+//go:embed css/*.min.css data/*.min.json data/site.webmanifest img/*.png img/*.svg js/*.min.js migrations/*/*.sql *.min.html favicon.ico
 var embedFS embed.FS
 
 // GetFileSystem returns an http.FileSystem interface for the embedded assets,
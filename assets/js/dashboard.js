@@ -8,6 +8,7 @@ document.addEventListener('DOMContentLoaded', () => {
   const serverTable = document.getElementById('serverTable');
   const tableBody = document.getElementById('tableBody');
   const searchInput = document.getElementById('searchTable');
+  const tagSelector = document.getElementById('tagSelector');
   const btnPrev = document.getElementById('btnPrev');
   const btnNext = document.getElementById('btnNext');
   const pageInfo = document.getElementById('pageInfo');
@@ -17,7 +18,24 @@ document.addEventListener('DOMContentLoaded', () => {
   const serverModal = new bootstrap.Modal(document.getElementById('serverModal'));
   const infoModal = new bootstrap.Modal(document.getElementById('infoModal'));
   const deleteModal = new bootstrap.Modal(document.getElementById('deleteModal'));
+  const trashModal = new bootstrap.Modal(document.getElementById('trashModal'));
+  const trashBody = document.getElementById('trashBody');
   const jsonContent = document.getElementById('jsonContent');
+  const extraFields = document.getElementById('extraFields');
+  const extraBody = document.getElementById('extraBody');
+  const nodeUptime = document.getElementById('nodeUptime');
+  const nodeSessions = document.getElementById('nodeSessions');
+  const nodeSessionsBody = document.getElementById('nodeSessionsBody');
+  const nodeSessionStats = document.getElementById('nodeSessionStats');
+  const historyChartEl = document.getElementById('historyChart');
+  let historyChart = null;
+  const annotationForm = document.getElementById('annotationForm');
+  const annotationTags = document.getElementById('annotationTags');
+  const annotationContact = document.getElementById('annotationContact');
+  const annotationNotes = document.getElementById('annotationNotes');
+  const annotationStatus = document.getElementById('annotationStatus');
+  const btnSaveAnnotation = document.getElementById('btnSaveAnnotation');
+  let infoNodeId = null;
   const modalTitle = document.getElementById('modalTitle');
   const modalBody = document.getElementById('modalBody');
 
@@ -27,8 +45,14 @@ document.addEventListener('DOMContentLoaded', () => {
   const btnConfirmDelete = document.getElementById('btnConfirmDelete');
 
   // Data State
-  let rawData = [];
-  let filteredData = []; // Data currently displayed (after app/time/search filters)
+  let versionEvents = [];
+  let activityRequest = 0; // Sequence number to drop stale activity responses
+  let sessionsRequest = 0; // Sequence number to drop stale session responses
+  let uptimeRequest = 0; // Sequence number to drop stale uptime responses
+  let summaryRequest = 0; // Sequence number to drop stale summary responses
+  let tableTotal = 0; // Total rows matching table filters on the server
+  let tableRequest = 0; // Sequence number to drop stale table responses
+  let currentCutoff = null; // Time filter shared by charts and table
   let isoMap = {};
   let nameToIso = {};
   let currentCountry = null;
@@ -44,6 +68,8 @@ document.addEventListener('DOMContentLoaded', () => {
   let sortDir = 'desc'; // 'asc' | 'desc'
   let currentPage = 1;
   const itemsPerPage = 15;
+  const pageCursors = ['']; // cursor of every visited page, the first page has none
+  let nextCursor = ''; // cursor of the page after the current one, empty on the last page
 
   const initChart = (id) => {
     const el = document.getElementById(id);
@@ -58,6 +84,8 @@ document.addEventListener('DOMContentLoaded', () => {
     version: initChart('pieVersion'),
     topServers: initChart('barTopServers'),
     mapName: initChart('pieMap'),
+    adoption: initChart('adoptionChart'),
+    activity: initChart('activityChart'),
   };
   bindPieFilter(charts.country, 'country');
   bindPieFilter(charts.os, 'os');
@@ -79,6 +107,11 @@ document.addEventListener('DOMContentLoaded', () => {
     }
   });
 
+  document.getElementById('btnTrash').addEventListener('click', () => {
+    trashModal.show();
+    loadTrash();
+  });
+
   if (charts.map) {
     charts.map.on('click', function (params) {
       const countryName = params.name;
@@ -98,35 +131,52 @@ document.addEventListener('DOMContentLoaded', () => {
   }
 
   const mapPromise = fetch('https://raw.githubusercontent.com/apache/echarts-examples/master/public/data/asset/geo/world.json').then(r => r.json());
-  const statsPromise = fetch('/api/stats', {
+  const appsPromise = fetch('/api/summary?top=0', {
     headers: {
       'Authorization': `Bearer ${token}`
     }
-  }).then(r => r.json());
+  }).then(r => r.json()).then(s => s.applications || []);
   const isoPromise = fetch('/data/iso3166.min.json').then(r => r.json());
 
-  Promise.all([mapPromise, statsPromise, isoPromise])
-    .then(([mapGeoJson, statsData, isoData]) => {
+  const eventsPromise = fetch('/api/versions/events', {
+    headers: {
+      'Authorization': `Bearer ${token}`
+    }
+  }).then(r => r.json()).catch(() => []);
+
+  Promise.all([mapPromise, appsPromise, isoPromise, eventsPromise])
+    .then(([mapGeoJson, apps, isoData, eventsData]) => {
       echarts.registerMap('world', mapGeoJson);
-      rawData = statsData || [];
+      versionEvents = eventsData || [];
       isoMap = isoData || {};
       Object.entries(isoMap).forEach(([code, name]) => nameToIso[name] = code);
 
-      populateSelector(rawData);
+      populateSelector(apps);
       updateDashboard();
     })
     .catch(console.error);
 
+  loadTags();
+
   // --- EVENTS ---
   appSelector.addEventListener('change', () => updateDashboard());
   timeSelector.addEventListener('change', () => updateDashboard());
 
-  // Table Search
-  searchInput.addEventListener('input', () => {
+  tagSelector.addEventListener('change', () => {
     currentPage = 1;
     renderTable();
   });
 
+  // Table Search (debounced, filtering is done by the server)
+  let searchTimer = null;
+  searchInput.addEventListener('input', () => {
+    clearTimeout(searchTimer);
+    searchTimer = setTimeout(() => {
+      currentPage = 1;
+      renderTable();
+    }, 300);
+  });
+
   // Pagination
   btnPrev.addEventListener('click', () => {
     if (currentPage > 1) {
@@ -135,8 +185,8 @@ document.addEventListener('DOMContentLoaded', () => {
     }
   });
   btnNext.addEventListener('click', () => {
-    const maxPage = Math.ceil(filteredData.length / itemsPerPage);
-    if (currentPage < maxPage) {
+    if (nextCursor) {
+      pageCursors[currentPage] = nextCursor;
       currentPage++;
       renderTable();
     }
@@ -144,8 +194,32 @@ document.addEventListener('DOMContentLoaded', () => {
 
   // --- LOGIC ---
 
-  function populateSelector(data) {
-    const apps = [...new Set(data.map(d => d.application))];
+  function loadTags() {
+    fetch('/api/tags', {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error(r.statusText);
+        return r.json();
+      })
+      .then(tags => {
+        const selected = tagSelector.value;
+        tagSelector.length = 1; // keep "All Tags"
+        tags.forEach(t => {
+          const opt = document.createElement('option');
+          opt.value = t.name;
+          opt.innerText = `${t.name} (${t.count})`;
+          tagSelector.appendChild(opt);
+        });
+        tagSelector.value = tags.some(t => t.name === selected) ? selected : '';
+      })
+      .catch(console.error);
+  }
+
+  function populateSelector(groups) {
+    const apps = groups.map(g => g.name).filter(Boolean);
     apps.sort();
     apps.forEach(app => {
       const opt = document.createElement('option');
@@ -174,90 +248,98 @@ document.addEventListener('DOMContentLoaded', () => {
       timelineHeader.innerText = "Activity Timeline (All Time)";
     }
 
-    // Global Filter for Charts
-    const data = rawData.filter(d => {
-      const matchApp = (filterApp === 'all' || d.application === filterApp);
-      const matchCountry =
-        (currentCountry === null || d.country_code === currentCountry) &&
-        (pieFilters.country === null || d.country_code === pieFilters.country);
-
-      const matchOS = pieFilters.os === null || d.server_os === pieFilters.os;
-      const matchVer = pieFilters.version === null || d.version === pieFilters.version;
-      const matchMap = pieFilters.map === null || d.map_name === pieFilters.map;
-
-      const seenTime = new Date(d.last_seen);
-      const matchTime = seenTime >= cutoff;
+    // Charts are built from server-side aggregates with the same filters as the table
+    currentCutoff = filterTime === 'all' ? null : cutoff;
+    renderSummary(filterTime === '24h' ? 'hour' : 'day');
+    if (charts.adoption) renderAdoption(filterApp, cutoff);
+    if (charts.activity) renderActivity(filterApp, filterTime);
+    renderVersionSessions(filterApp, currentCutoff);
+    renderVersionUptime(filterApp, currentCutoff);
 
-      return matchApp && matchCountry && matchOS && matchVer && matchMap && matchTime;
-    });
-
-    calculateStats(data);
-    renderCharts(data);
-
-    // Reset Table
+    // Reset Table, it is loaded page by page with the same filters as charts
     currentPage = 1;
-    // For table, we use the same filtered data as charts initially
-    filteredData = [...data];
     sortKey = 'count';
     sortDir = 'desc';
 
     renderTable();
   }
 
+  function filterParams() {
+    const params = new URLSearchParams();
+    const filterApp = appSelector.value;
+    const country = pieFilters.country || currentCountry;
+
+    if (filterApp !== 'all') params.set('app', filterApp);
+    if (country) params.set('country', country);
+    if (pieFilters.os) params.set('os', pieFilters.os);
+    if (pieFilters.version) params.set('version', pieFilters.version);
+    if (pieFilters.map) params.set('map', pieFilters.map);
+    if (currentCutoff) params.set('since', currentCutoff.toISOString());
+    return params;
+  }
+
+  function renderSummary(bucket) {
+    const request = ++summaryRequest;
+    const params = filterParams();
+    params.set('bucket', bucket);
+
+    fetch(`/api/summary?${params.toString()}`, {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error(r.statusText);
+        return r.json();
+      })
+      .then(summary => {
+        if (request !== summaryRequest) return; // a newer request is in flight
+        calculateStats(summary);
+        renderCharts(summary);
+      })
+      .catch(console.error);
+  }
+
   // --- TABLE LOGIC ---
-  function renderTable() {
-    const query = searchInput.value.toLowerCase();
-    let displayData = filteredData;
-
-    if (query) {
-      displayData = filteredData.filter(d =>
-        (d.server_name && d.server_name.toLowerCase().includes(query)) ||
-        (d.ip && d.ip.includes(query))
-      );
-    }
+  function tableParams() {
+    const params = filterParams();
+
+    const query = searchInput.value.trim();
+    if (query) params.set('q', query);
+    if (tagSelector.value) params.set('tag', tagSelector.value);
+
+    params.set('sort', sortKey);
+    params.set('order', sortDir);
+    params.set('limit', itemsPerPage);
+    if (pageCursors[currentPage - 1]) params.set('cursor', pageCursors[currentPage - 1]);
+    return params;
+  }
 
-    const toTs = (v) => {
-      const t = Date.parse(v || '');
-      return Number.isFinite(t) ? t : 0;
-    };
-    const toNum = (v) => Number.isFinite(Number(v)) ? Number(v) : 0;
-    const toStr = (v) => (v ?? '').toString().toLowerCase();
-
-    const val = (d, k) => {
-      switch (k) {
-        case 'address':
-          return `${d.ip || ''}:${d.port || ''}`;
-        case 'players':
-          return toNum(d.players);
-        case 'count':
-          return toNum(d.count);
-        case 'first_seen':
-          return toTs(d.first_seen);
-        case 'last_seen':
-          return toTs(d.last_seen);
-        default:
-          return toStr(d[k]);
-      }
-    };
+  function renderTable() {
+    const request = ++tableRequest;
 
-    if (sortKey) {
-      const dir = (sortDir === 'asc') ? 1 : -1;
-      displayData = [...displayData].sort((a, b) => {
-        const va = val(a, sortKey);
-        const vb = val(b, sortKey);
-        if (typeof va === 'number' && typeof vb === 'number') return (va - vb) * dir;
-        if (va < vb) return -1 * dir;
-        if (va > vb) return 1 * dir;
-        return 0;
-      });
-    }
+    fetch(`/api/nodes?${tableParams().toString()}`, {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error(r.statusText);
+        return r.json();
+      })
+      .then(page => {
+        if (request !== tableRequest) return; // a newer request is in flight
+        tableTotal = page.total || 0;
+        nextCursor = page.next_cursor || '';
+        renderRows(page.nodes || []);
+      })
+      .catch(console.error);
+  }
 
-    // Pagination
-    const totalItems = displayData.length;
+  function renderRows(pageData) {
+    const totalItems = tableTotal;
     const start = (currentPage - 1) * itemsPerPage;
     const end = start + itemsPerPage;
-    const pageData = displayData.slice(start, end);
-
 
     // Render HTML
     tableBody.innerHTML = '';
@@ -286,14 +368,16 @@ document.addEventListener('DOMContentLoaded', () => {
           <div class="text-truncate" style="max-width: 250px;" title="${d.server_name || 'N/A'}">
             ${d.server_name || '<span class="text-muted">Unknown</span>'}
           </div>
+          ${(d.tags || []).map(t => `<span class="badge bg-secondary me-1">${escapeHtml(t)}</span>`).join('')}
         </td>
         <td>
           <span class="badge bg-dark border border-secondary text-light font-monospace">${d.ip}:${d.port}</span> <small>${flag}</small>
         </td>
         <td>
           ${d.application}
-          <span class="badge bg-dark border border-secondary text-light font-monospace">${d.version}</span>
+          ${d.verified ? '<span class="badge bg-success" title="Last report was signed">✓</span>' : ''}
         </td>
+        <td><span class="badge bg-dark border border-secondary text-light font-monospace">${d.version || '-'}</span></td>
         <td>${d.map_name || '-'}</td>
         <td><span class="text-success">${d.players}</span> / <span class="text-muted">${d.max_players}</span></td>
         <td><strong>${d.count}</strong></td>
@@ -306,11 +390,11 @@ document.addEventListener('DOMContentLoaded', () => {
               Ping
             </button>
             <button class="btn btn-outline-info" title="View JSON"
-              onclick="showInfo('${d.application}', '${d.ip}', ${d.port})">
+              onclick="showInfo(${d.id})">
               Info
             </button>
             <button class="btn btn-outline-danger" title="Delete Node"
-              onclick="askDelete('${d.application}', '${d.ip}', ${d.port})">
+              onclick="askDelete(${d.id}, '${d.application}', '${d.ip}', ${d.port})">
               Del
             </button>
           </div>
@@ -322,7 +406,7 @@ document.addEventListener('DOMContentLoaded', () => {
     // Update Controls
     pageInfo.innerText = `Showing ${Math.min(start + 1, totalItems)}-${Math.min(end, totalItems)} of ${totalItems}`;
     btnPrev.disabled = currentPage === 1;
-    btnNext.disabled = end >= totalItems;
+    btnNext.disabled = !nextCursor;
   }
 
   // --- PING MODAL LOGIC (Global Scope) ---
@@ -404,16 +488,16 @@ document.addEventListener('DOMContentLoaded', () => {
   };
 
   // --- INFO MODAL LOGIC ---
-  window.showInfo = function (app, ip, port) {
+  window.showInfo = function (id) {
     jsonContent.innerText = "Loading...";
+    renderExtra(null);
+    renderNodeSessions([]);
+    renderNodeUptime(null);
+    infoNodeId = id;
+    loadAnnotation(id);
     infoModal.show();
 
-    const params = new URLSearchParams({
-      app,
-      ip,
-      port
-    });
-    fetch(`/api/node?${params.toString()}`, {
+    fetch(`/api/nodes/${id}`, {
         headers: {
           'Authorization': `Bearer ${token}`
         }
@@ -423,24 +507,304 @@ document.addEventListener('DOMContentLoaded', () => {
         return r.json();
       })
       .then(data => {
+        renderExtra(data.extra);
         jsonContent.innerText = JSON.stringify(data, null, 2);
       })
       .catch(err => {
         jsonContent.innerText = "Error loading data: " + err.message;
       });
+
+    fetch(`/api/nodes/${id}/history`, {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error("Not found");
+        return r.json();
+      })
+      .then(history => renderHistory(history || []))
+      .catch(() => renderHistory([]));
+
+    fetch(`/api/nodes/${id}/sessions`, {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error("Not found");
+        return r.json();
+      })
+      .then(sessions => {
+        if (id === infoNodeId) renderNodeSessions(sessions || []);
+      })
+      .catch(() => renderNodeSessions([]));
+
+    fetch(`/api/nodes/${id}/uptime`, {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error("Not found");
+        return r.json();
+      })
+      .then(uptime => {
+        if (id === infoNodeId) renderNodeUptime(uptime);
+      })
+      .catch(() => renderNodeUptime(null));
   };
 
-  // --- DELETE MODAL LOGIC ---
-  window.askDelete = function (app, ip, port) {
-    targetToDelete = {
-      app,
-      ip,
-      port
+  function loadAnnotation(id) {
+    annotationForm.reset();
+    annotationStatus.innerText = "Loading...";
+    btnSaveAnnotation.disabled = true;
+
+    fetch(`/api/nodes/${id}/notes`, {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error(r.statusText);
+        return r.json();
+      })
+      .then(a => {
+        if (id !== infoNodeId) return; // another node was opened meanwhile
+        annotationTags.value = (a.tags || []).join(', ');
+        annotationContact.value = a.owner_contact || '';
+        annotationNotes.value = a.notes || '';
+        annotationStatus.innerText = a.notes || a.owner_contact || (a.tags || []).length ?
+          `Updated ${new Date(a.updated_at).toLocaleString()}` : '';
+        btnSaveAnnotation.disabled = false;
+      })
+      .catch(err => {
+        annotationStatus.innerText = "Error loading notes: " + err.message;
+      });
+  }
+
+  annotationForm.addEventListener('submit', (e) => {
+    e.preventDefault();
+    if (!infoNodeId) return;
+
+    const id = infoNodeId;
+    btnSaveAnnotation.disabled = true;
+    annotationStatus.innerText = "Saving...";
+
+    fetch(`/api/nodes/${id}/notes`, {
+        method: 'PUT',
+        headers: {
+          'Authorization': `Bearer ${token}`,
+          'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({
+          tags: annotationTags.value.split(',').map(t => t.trim()).filter(Boolean),
+          owner_contact: annotationContact.value,
+          notes: annotationNotes.value
+        })
+      })
+      .then(r => {
+        if (!r.ok) return r.text().then(t => {
+          throw new Error(t.trim() || r.statusText);
+        });
+        return r.json();
+      })
+      .then(a => {
+        annotationTags.value = (a.tags || []).join(', ');
+        annotationStatus.innerText = "Saved";
+        loadTags();
+        renderTable();
+      })
+      .catch(err => {
+        annotationStatus.innerText = "Error: " + err.message;
+      })
+      .finally(() => {
+        btnSaveAnnotation.disabled = false;
+      });
+  });
+
+  // Chart is created lazily, the modal must be visible to get real dimensions
+  document.getElementById('infoModal').addEventListener('shown.bs.modal', () => {
+    if (historyChart) historyChart.resize();
+  });
+
+  // Custom fields reported by the node, hidden when there are none
+  function renderExtra(extra) {
+    const keys = Object.keys(extra || {}).sort();
+    extraFields.classList.toggle('d-none', keys.length === 0);
+    extraBody.innerHTML = keys.map(k => `
+      <tr>
+        <td class="text-muted">${escapeHtml(k)}</td>
+        <td>${escapeHtml(String(extra[k]))}</td>
+      </tr>
+    `).join('');
+  }
+
+  // Availability of the node over the last 30 days, hidden for nodes without heartbeat tracking
+  function renderNodeUptime(uptime) {
+    const tracked = uptime && uptime.tracked;
+    nodeUptime.classList.toggle('d-none', !tracked);
+    if (!tracked) return;
+
+    const state = uptime.up ?
+      `<span class="text-success">up for ${formatDuration(uptime.current_streak)}</span>` :
+      `<span class="text-danger">down since ${new Date(uptime.last_seen).toLocaleString()}</span>`;
+    nodeUptime.innerHTML = `Availability <strong>${(uptime.availability * 100).toFixed(2)}%</strong> in 30 days · ` +
+      `${uptime.outages} outages, longest ${formatDuration(uptime.longest_outage)} · ${state}`;
+  }
+
+  // Sessions of the node from lifecycle events, hidden when it reports none
+  function renderNodeSessions(sessions) {
+    nodeSessions.classList.toggle('d-none', sessions.length === 0);
+
+    const closed = sessions.filter(s => s.state !== 'open');
+    const crashes = closed.filter(s => s.state === 'unclean').length;
+    const uptime = sessions.reduce((sum, s) => sum + s.duration, 0);
+    nodeSessionStats.innerText = sessions.length ?
+      `· ${sessions.length} in 30 days · uptime ${formatDuration(uptime)} · ${crashes} unclean` : '';
+
+    const badges = {
+      open: 'bg-primary',
+      clean: 'bg-success',
+      unclean: 'bg-danger'
     };
+    nodeSessionsBody.innerHTML = sessions.slice(0, 10).map(s => `
+      <tr>
+        <td>${new Date(s.started_at).toLocaleString()}</td>
+        <td class="font-monospace">${escapeHtml(s.version) || '-'}</td>
+        <td>${formatDuration(s.duration)}</td>
+        <td><span class="badge ${badges[s.state] || 'bg-secondary'}">${s.state}</span></td>
+      </tr>
+    `).join('');
+  }
+
+  function renderHistory(history) {
+    if (!historyChartEl) return;
+    if (!historyChart) {
+      historyChart = echarts.init(historyChartEl);
+    }
+
+    historyChart.setOption({
+      backgroundColor: 'transparent',
+      tooltip: {
+        trigger: 'axis',
+        formatter: function (params) {
+          const p = params[0];
+          const s = history[p.dataIndex];
+          const dt = new Date(s.time).toLocaleString();
+          const state = s.online ? (s.map_name || '-') : 'offline';
+          return `${dt}<br/>${s.players} / ${s.max_players} (${state})`;
+        }
+      },
+      grid: {
+        left: '10',
+        right: '20',
+        bottom: '10',
+        top: '10',
+        containLabel: true
+      },
+      xAxis: {
+        type: 'time'
+      },
+      yAxis: {
+        type: 'value',
+        minInterval: 1,
+        splitLine: {
+          lineStyle: {
+            color: '#373b41'
+          }
+        }
+      },
+      series: [{
+        name: 'Players',
+        type: 'line',
+        step: 'end',
+        showSymbol: false,
+        data: history.map(s => [s.time, s.players]),
+        lineStyle: {
+          color: '#00a8e8',
+          width: 2
+        }
+      }]
+    }, true);
+  }
+
+  // --- DELETE MODAL LOGIC ---
+  window.askDelete = function (id, app, ip, port) {
+    targetToDelete = id;
     deleteTargetText.innerText = `${app} @ ${ip}:${port}`;
     deleteModal.show();
   };
 
+  // --- TRASH MODAL LOGIC ---
+  function loadTrash() {
+    trashBody.innerHTML = '<tr><td colspan="7" class="text-center text-muted">Loading...</td></tr>';
+
+    fetch('/api/nodes/deleted', {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error(r.statusText);
+        return r.json();
+      })
+      .then(renderTrash)
+      .catch(err => {
+        trashBody.innerHTML = `<tr><td colspan="7" class="text-center text-danger">Error: ${escapeHtml(err.message)}</td></tr>`;
+      });
+  }
+
+  function renderTrash(nodes) {
+    if (!nodes.length) {
+      trashBody.innerHTML = '<tr><td colspan="7" class="text-center text-muted">Trash is empty</td></tr>';
+      return;
+    }
+
+    trashBody.innerHTML = '';
+    nodes.forEach(d => {
+      const row = document.createElement('tr');
+      row.innerHTML = `
+        <td>${escapeHtml(d.server_name) || '<span class="text-muted">Unknown</span>'}</td>
+        <td class="font-monospace small">${d.ip}:${d.port}</td>
+        <td><span class="badge bg-secondary">${d.application}</span></td>
+        <td>${d.count}</td>
+        <td class="small text-muted">${new Date(d.last_seen).toLocaleString()}</td>
+        <td class="small text-muted">${new Date(d.deleted_at).toLocaleString()}</td>
+        <td>
+          <button class="btn btn-outline-success btn-sm" title="Restore Node"
+            onclick="restoreNode(this, ${d.id})">
+            Restore
+          </button>
+        </td>
+      `;
+      trashBody.appendChild(row);
+    });
+  }
+
+  window.restoreNode = function (btn, id) {
+    btn.disabled = true;
+
+    fetch(`/api/nodes/${id}/restore`, {
+        method: 'POST',
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error(r.statusText);
+        return r.json();
+      })
+      .then(() => {
+        loadTrash();
+        updateDashboard();
+      })
+      .catch(err => {
+        btn.disabled = false;
+        alert("Restore failed: " + err.message);
+      });
+  };
+
   // Helpers
   function escapeHtml(text) {
     if (!text) return text;
@@ -452,36 +816,55 @@ document.addEventListener('DOMContentLoaded', () => {
       .replace(/'/g, "&#039;");
   }
 
-  function calculateStats(data) {
-    // total
-    animateValue("valTotal", data.length);
+  // Formats a duration in seconds as "1d 2h", "3h 4m" or "5m"
+  function formatDuration(seconds) {
+    const d = Math.floor(seconds / 86400);
+    const h = Math.floor(seconds % 86400 / 3600);
+    const m = Math.floor(seconds % 3600 / 60);
+    if (d) return `${d}d ${h}h`;
+    if (h) return `${h}h ${m}m`;
+    return `${m}m`;
+  }
 
-    // unique online IP:Port
-    const online = data.filter(d => d.game_version);
-    const uniqueServers = new Set(online.map(d => `${d.ip}:${d.port}`));
-    animateValue("valUnique", uniqueServers.size);
+  // Orders versions by semantic version precedence, non-semver versions go first by name
+  function compareVersions(a, b) {
+    const parse = v => {
+      const m = /^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+.*)?$/.exec(String(v).trim());
+      return m && {
+        core: [m[1], m[2] || 0, m[3] || 0].map(Number),
+        pre: m[4] ? m[4].split('.') : []
+      };
+    };
+    const va = parse(a), vb = parse(b);
+    if (!va || !vb) return (va ? 1 : 0) - (vb ? 1 : 0) || String(a).localeCompare(String(b));
 
-    // unique IP (host)
-    const uniqueHosts = new Set(data.map(d => d.ip));
-    animateValue("valHosts", uniqueHosts.size);
+    for (let i = 0; i < 3; i++) {
+      if (va.core[i] !== vb.core[i]) return va.core[i] - vb.core[i];
+    }
+    // A release has higher precedence than its prereleases
+    if (!va.pre.length || !vb.pre.length) return vb.pre.length - va.pre.length;
+    for (let i = 0; i < Math.min(va.pre.length, vb.pre.length); i++) {
+      const x = va.pre[i], y = vb.pre[i];
+      if (x === y) continue;
+      const nx = /^\d+$/.test(x), ny = /^\d+$/.test(y);
+      if (nx && ny) return Number(x) - Number(y);
+      if (nx !== ny) return nx ? -1 : 1;
+      return x < y ? -1 : 1;
+    }
+    return va.pre.length - vb.pre.length;
+  }
 
-    // players
-    let totalPlayers = 0;
-    const processed = new Set();
-    data.forEach(d => {
-      const key = `${d.ip}:${d.port}`;
-      if (!processed.has(key)) {
-        totalPlayers += (d.players || 0);
-        processed.add(key);
-      }
-    });
-    animateValue("valPlayers", totalPlayers);
+  function calculateStats(summary) {
+    animateValue("valTotal", summary.total);
+    animateValue("valUnique", summary.unique_servers);
+    animateValue("valHosts", summary.unique_hosts);
+    animateValue("valPlayers", summary.players);
   }
 
-  function renderCharts(data) {
-    if (charts.map) renderMap(data);
-    if (charts.line) renderTimeline(data);
-    if (charts.topServers) renderTopServers(data);
+  function renderCharts(summary) {
+    if (charts.map) renderMap(summary.countries || []);
+    if (charts.line) renderTimeline(summary.timeline || []);
+    if (charts.topServers) renderTopServers(summary.top_servers || []);
     const pieConfig = (name, data) => ({
       backgroundColor: 'transparent',
       tooltip: {
@@ -521,18 +904,18 @@ document.addEventListener('DOMContentLoaded', () => {
         data: data
       }]
     });
-    if (charts.country) charts.country.setOption(pieConfig('Countries', getTopN(data, 'country_code', 10)));
-    if (charts.os) charts.os.setOption(pieConfig('OS', getGrouped(data, 'server_os')));
-    if (charts.version) charts.version.setOption(pieConfig('App Version', getGrouped(data, 'version')));
-    if (charts.mapName) charts.mapName.setOption(pieConfig('Maps', getGrouped(data, 'map_name')));
+    if (charts.country) charts.country.setOption(pieConfig('Countries', toPie(summary.countries).slice(0, 10)));
+    if (charts.os) charts.os.setOption(pieConfig('OS', toPie(summary.os)));
+    if (charts.version) charts.version.setOption(pieConfig('App Version', toPie(summary.versions)));
+    if (charts.mapName) charts.mapName.setOption(pieConfig('Maps', toPie(summary.maps)));
   }
 
-  function renderMap(data) {
+  function renderMap(countries) {
     const counts = {};
-    data.forEach(d => {
-      const cc = d.country_code ? d.country_code.toUpperCase() : "UNKNOWN";
+    countries.forEach(g => {
+      const cc = g.name ? g.name.toUpperCase() : "UNKNOWN";
       const fullName = isoMap[cc] || cc;
-      counts[fullName] = (counts[fullName] || 0) + 1;
+      counts[fullName] = (counts[fullName] || 0) + g.count;
     });
     const mapData = Object.keys(counts).map(k => ({
       name: k,
@@ -600,24 +983,11 @@ document.addEventListener('DOMContentLoaded', () => {
     });
   }
 
-  function renderTimeline(data) {
+  function renderTimeline(timeline) {
     const filterTime = timeSelector.value;
-    const sorted = [...data].sort((a, b) => new Date(a.last_seen) - new Date(b.last_seen));
-    const timeBuckets = {};
-    sorted.forEach(d => {
-      const date = new Date(d.last_seen);
-      if (filterTime === '30d' || filterTime === 'all' || filterTime === '7d') {
-        date.setHours(0, 0, 0, 0);
-      } else {
-        date.setMinutes(0, 0, 0);
-      }
-      const key = date.toISOString();
-      timeBuckets[key] = (timeBuckets[key] || 0) + 1;
-    });
-    const keys = Object.keys(timeBuckets).sort();
-    const values = keys.map(k => timeBuckets[k]);
-    const labels = keys.map(k => {
-      const d = new Date(k);
+    const values = timeline.map(t => t.count);
+    const labels = timeline.map(t => {
+      const d = new Date(t.bucket);
       if (filterTime === '24h') return d.toLocaleTimeString([], {
         hour: '2-digit',
         minute: '2-digit'
@@ -675,9 +1045,310 @@ document.addEventListener('DOMContentLoaded', () => {
     });
   }
 
-  function renderTopServers(data) {
-    const top = [...data].sort((a, b) => b.count - a.count).slice(0, 20);
-    top.reverse();
+  // Replays version events day by day and counts nodes running each version
+  function renderAdoption(filterApp, cutoff) {
+    const events = versionEvents.filter(e => filterApp === 'all' || e.application === filterApp);
+    const dayMs = 24 * 60 * 60 * 1000;
+    const days = [];
+    const perDay = [];
+
+    if (events.length) {
+      const first = new Date(events[0].time);
+      first.setHours(0, 0, 0, 0);
+      const end = new Date();
+      end.setHours(0, 0, 0, 0);
+
+      const current = {};
+      let i = 0;
+      for (let day = first.getTime(); day <= end.getTime(); day += dayMs) {
+        const boundary = day + dayMs;
+        while (i < events.length && Date.parse(events[i].time) < boundary) {
+          current[events[i].node_id] = events[i].new_version || 'Unknown';
+          i++;
+        }
+        if (boundary <= cutoff.getTime()) continue;
+
+        const counts = {};
+        Object.values(current).forEach(v => counts[v] = (counts[v] || 0) + 1);
+        days.push(day);
+        perDay.push(counts);
+      }
+    }
+
+    // Keep most used versions (by last day), fold the rest into "Other"
+    const last = perDay.length ? perDay[perDay.length - 1] : {};
+    const top = Object.entries(last).sort((a, b) => b[1] - a[1]).slice(0, 8).map(([v]) => v);
+    const hasOther = perDay.some(c => Object.keys(c).some(v => !top.includes(v)));
+    top.sort(compareVersions);
+    const versions = hasOther ? [...top, 'Other'] : top;
+
+    const series = versions.map(v => ({
+      name: v,
+      type: 'line',
+      stack: 'versions',
+      smooth: true,
+      showSymbol: false,
+      areaStyle: {
+        opacity: 0.6
+      },
+      data: perDay.map(c => v === 'Other' ?
+        Object.entries(c).filter(([k]) => !top.includes(k)).reduce((sum, [, n]) => sum + n, 0) :
+        (c[v] || 0))
+    }));
+
+    charts.adoption.setOption({
+      backgroundColor: 'transparent',
+      tooltip: {
+        trigger: 'axis'
+      },
+      legend: {
+        top: 5,
+        textStyle: {
+          color: '#9fa5b0'
+        }
+      },
+      color: ['#00ab44', '#00a8e8', '#e8a800', '#8931ef', '#f44336', '#00bcd4', '#ff9800', '#9c27b0', '#607d8b'],
+      grid: {
+        left: '30',
+        right: '30',
+        bottom: '20',
+        top: '40',
+        containLabel: true
+      },
+      xAxis: {
+        type: 'category',
+        boundaryGap: false,
+        data: days.map(d => new Date(d).toLocaleDateString([], {
+          month: 'short',
+          day: 'numeric'
+        }))
+      },
+      yAxis: {
+        type: 'value',
+        splitLine: {
+          lineStyle: {
+            color: '#373b41'
+          }
+        }
+      },
+      series
+    }, true);
+  }
+
+  // Daily, weekly and monthly active servers, summed over applications when all are selected
+  function renderActivity(filterApp, filterTime) {
+    const request = ++activityRequest;
+    const dayMs = 24 * 60 * 60 * 1000;
+    const rangeDays = {
+      '24h': 7,
+      '7d': 7,
+      '30d': 30
+    } [filterTime] || 365;
+
+    const params = new URLSearchParams({
+      from: new Date(Date.now() - (rangeDays - 1) * dayMs).toISOString()
+    });
+    if (filterApp !== 'all') params.set('app', filterApp);
+
+    fetch(`/api/activity?${params.toString()}`, {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error(r.statusText);
+        return r.json();
+      })
+      .then(apps => {
+        if (request !== activityRequest) return; // a newer request is in flight
+
+        const totals = {};
+        apps.forEach(a => a.days.forEach(d => {
+          const t = totals[d.day] || (totals[d.day] = {
+            dau: 0,
+            wau: 0,
+            mau: 0
+          });
+          t.dau += d.dau;
+          t.wau += d.wau;
+          t.mau += d.mau;
+        }));
+        const days = Object.keys(totals).sort();
+        const last = days.length ? totals[days[days.length - 1]] : {
+          dau: 0,
+          wau: 0,
+          mau: 0
+        };
+
+        const stickiness = last.mau ? Math.round(last.dau / last.mau * 100) : 0;
+        document.getElementById('activityStats').innerText =
+          `DAU ${last.dau} · WAU ${last.wau} · MAU ${last.mau} · Stickiness ${stickiness}%`;
+
+        const line = (name, key) => ({
+          name,
+          type: 'line',
+          smooth: true,
+          showSymbol: false,
+          data: days.map(d => totals[d][key])
+        });
+
+        charts.activity.setOption({
+          backgroundColor: 'transparent',
+          tooltip: {
+            trigger: 'axis'
+          },
+          legend: {
+            top: 5,
+            textStyle: {
+              color: '#9fa5b0'
+            }
+          },
+          color: ['#00ab44', '#00a8e8', '#e8a800'],
+          grid: {
+            left: '30',
+            right: '30',
+            bottom: '20',
+            top: '40',
+            containLabel: true
+          },
+          xAxis: {
+            type: 'category',
+            boundaryGap: false,
+            data: days.map(d => new Date(d).toLocaleDateString([], {
+              month: 'short',
+              day: 'numeric',
+              timeZone: 'UTC'
+            }))
+          },
+          yAxis: {
+            type: 'value',
+            minInterval: 1,
+            splitLine: {
+              lineStyle: {
+                color: '#373b41'
+              }
+            }
+          },
+          series: [line('DAU', 'dau'), line('WAU', 'wau'), line('MAU', 'mau')]
+        }, true);
+      })
+      .catch(console.error);
+  }
+
+  // Session counts, uptime and crash rate per version, newest versions first
+  function renderVersionSessions(filterApp, cutoff) {
+    const request = ++sessionsRequest;
+    const params = new URLSearchParams({
+      from: (cutoff || new Date(0)).toISOString()
+    });
+    if (filterApp !== 'all') params.set('app', filterApp);
+
+    fetch(`/api/versions/sessions?${params.toString()}`, {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error(r.statusText);
+        return r.json();
+      })
+      .then(stats => {
+        if (request !== sessionsRequest) return; // a newer request is in flight
+
+        const body = document.getElementById('versionSessionsBody');
+        if (!stats.length) {
+          body.innerHTML = '<tr><td colspan="7" class="text-center text-muted">No lifecycle events reported</td></tr>';
+          document.getElementById('sessionStats').innerText = '';
+          return;
+        }
+
+        const total = stats.reduce((t, s) => {
+          t.sessions += s.sessions;
+          t.open += s.open;
+          t.closed += s.clean + s.unclean;
+          t.unclean += s.unclean;
+          return t;
+        }, {
+          sessions: 0,
+          open: 0,
+          closed: 0,
+          unclean: 0
+        });
+        const crashRate = total.closed ? Math.round(total.unclean / total.closed * 100) : 0;
+        document.getElementById('sessionStats').innerText =
+          `Sessions ${total.sessions} · Running ${total.open} · Crash Rate ${crashRate}%`;
+
+        const rows = [...stats].sort((a, b) =>
+          a.application.localeCompare(b.application) || compareVersions(b.version, a.version));
+        body.innerHTML = rows.map(s => {
+          const rate = s.clean + s.unclean ? `${(s.crash_rate * 100).toFixed(1)}%` : '-';
+          const warn = s.crash_rate >= 0.2 ? 'text-danger' : '';
+          return `
+            <tr>
+              <td>${escapeHtml(s.application)}</td>
+              <td><span class="badge bg-dark border border-secondary text-light font-monospace">${escapeHtml(s.version) || '-'}</span></td>
+              <td>${s.sessions}</td>
+              <td>${s.open}</td>
+              <td>${s.clean + s.unclean ? formatDuration(s.avg_uptime) : '-'}</td>
+              <td>${s.clean + s.unclean ? formatDuration(s.max_uptime) : '-'}</td>
+              <td class="${warn}">${rate}</td>
+            </tr>`;
+        }).join('');
+      })
+      .catch(console.error);
+  }
+
+  // Availability of nodes with heartbeat tracking per version, newest versions first
+  function renderVersionUptime(filterApp, cutoff) {
+    const request = ++uptimeRequest;
+    const params = new URLSearchParams({
+      from: (cutoff || new Date(0)).toISOString()
+    });
+    if (filterApp !== 'all') params.set('app', filterApp);
+
+    fetch(`/api/versions/uptime?${params.toString()}`, {
+        headers: {
+          'Authorization': `Bearer ${token}`
+        }
+      })
+      .then(r => {
+        if (!r.ok) throw new Error(r.statusText);
+        return r.json();
+      })
+      .then(stats => {
+        if (request !== uptimeRequest) return; // a newer request is in flight
+
+        const body = document.getElementById('versionUptimeBody');
+        if (!stats.length) {
+          body.innerHTML = '<tr><td colspan="7" class="text-center text-muted">No applications with a heartbeat interval</td></tr>';
+          document.getElementById('uptimeStats').innerText = '';
+          return;
+        }
+
+        const nodes = stats.reduce((n, s) => n + s.nodes, 0);
+        const up = stats.reduce((n, s) => n + s.up, 0);
+        const availability = stats.reduce((a, s) => a + s.availability * s.nodes, 0) / nodes;
+        document.getElementById('uptimeStats').innerText =
+          `Nodes ${nodes} · Up ${up} · Availability ${(availability * 100).toFixed(2)}%`;
+
+        const rows = [...stats].sort((a, b) =>
+          a.application.localeCompare(b.application) || compareVersions(b.version, a.version));
+        body.innerHTML = rows.map(s => `
+          <tr>
+            <td>${escapeHtml(s.application)}</td>
+            <td><span class="badge bg-dark border border-secondary text-light font-monospace">${escapeHtml(s.version) || '-'}</span></td>
+            <td>${s.nodes}</td>
+            <td>${s.up}</td>
+            <td class="${s.availability < 0.95 ? 'text-danger' : ''}">${(s.availability * 100).toFixed(2)}%</td>
+            <td>${s.outages}</td>
+            <td>${s.longest_outage ? formatDuration(s.longest_outage) : '-'}</td>
+          </tr>`).join('');
+      })
+      .catch(console.error);
+  }
+
+  function renderTopServers(servers) {
+    const top = [...servers].reverse();
     const names = top.map(d => d.server_name || d.ip);
     const values = top.map(d => d.count);
     charts.topServers.setOption({
@@ -726,27 +1397,10 @@ document.addEventListener('DOMContentLoaded', () => {
     });
   }
 
-  function getGrouped(data, field) {
-    const counts = {};
-    data.forEach(d => {
-      const val = d[field] || 'Unknown';
-      counts[val] = (counts[val] || 0) + 1;
-    });
-    return Object.keys(counts).map(k => ({
-      name: k,
-      value: counts[k]
-    }));
-  }
-
-  function getTopN(data, field, n) {
-    const counts = {};
-    data.forEach(d => {
-      const val = d[field] || 'Unknown';
-      counts[val] = (counts[val] || 0) + 1;
-    });
-    return Object.entries(counts).sort((a, b) => b[1] - a[1]).slice(0, n).map(([name, value]) => ({
-      name,
-      value
+  function toPie(groups) {
+    return (groups || []).map(g => ({
+      name: g.name || 'Unknown',
+      value: g.count
     }));
   }
 
@@ -776,13 +1430,11 @@ document.addEventListener('DOMContentLoaded', () => {
     });
   }
 
-  function executeDelete(target) {
-    const params = new URLSearchParams(target);
-
+  function executeDelete(id) {
     btnConfirmDelete.disabled = true;
     btnConfirmDelete.innerText = "Deleting...";
 
-    fetch(`/api/node?${params.toString()}`, {
+    fetch(`/api/nodes/${id}`, {
         method: 'DELETE',
         headers: {
           'Authorization': `Bearer ${token}`
@@ -791,7 +1443,6 @@ document.addEventListener('DOMContentLoaded', () => {
       .then(r => r.json())
       .then(res => {
         if (res.status === 'ok') {
-          rawData = rawData.filter(d => !(d.application === target.app && d.ip === target.ip && d.port === target.port));
           updateDashboard();
 
           deleteModal.hide();
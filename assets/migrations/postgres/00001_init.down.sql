@@ -0,0 +1,2 @@
+-- Revert initial schema setup
+DROP TABLE IF EXISTS nodes;
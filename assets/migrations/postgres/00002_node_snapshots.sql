@@ -0,0 +1,15 @@
+-- Per-report history of node state (players, map, A2S availability)
+CREATE TABLE IF NOT EXISTS node_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    seen_at TIMESTAMPTZ NOT NULL,
+    version TEXT,
+    map_name TEXT,
+    game_version TEXT,
+    players INTEGER,
+    max_players INTEGER,
+    online BOOLEAN DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_snapshots_node_seen ON node_snapshots(node_id, seen_at);
+CREATE INDEX IF NOT EXISTS idx_node_snapshots_seen ON node_snapshots(seen_at);
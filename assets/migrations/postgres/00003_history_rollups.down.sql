@@ -0,0 +1,4 @@
+-- Revert hourly and daily aggregates of node_snapshots
+DROP TABLE IF EXISTS rollup_state;
+DROP TABLE IF EXISTS node_stats_daily;
+DROP TABLE IF EXISTS node_stats_hourly;
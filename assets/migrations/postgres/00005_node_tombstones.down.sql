@@ -0,0 +1,4 @@
+-- Revert soft-delete tombstones, nodes in trash are deleted for good
+DELETE FROM nodes WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_nodes_deleted_at;
+ALTER TABLE nodes DROP COLUMN deleted_at;
@@ -0,0 +1,3 @@
+-- Revert admin annotations of nodes
+DROP TABLE IF EXISTS node_tags;
+DROP TABLE IF EXISTS node_annotations;
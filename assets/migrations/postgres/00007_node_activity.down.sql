@@ -0,0 +1,2 @@
+-- Revert daily node activity
+DROP TABLE IF EXISTS node_activity;
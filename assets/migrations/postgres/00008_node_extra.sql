@@ -0,0 +1,10 @@
+-- Custom fields reported by nodes in the telemetry "extra" object, replaced on every report carrying it
+CREATE TABLE IF NOT EXISTS node_extra (
+    node_id BIGINT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT,
+    kind TEXT NOT NULL,
+    PRIMARY KEY (node_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_extra_key_value ON node_extra(key, value);
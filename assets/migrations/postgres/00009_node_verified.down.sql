@@ -0,0 +1,2 @@
+-- Revert signed report marks
+ALTER TABLE nodes DROP COLUMN verified;
@@ -0,0 +1,2 @@
+-- Nodes whose last report was signed with the application secret
+ALTER TABLE nodes ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- Revert node sessions
+DROP TABLE IF EXISTS node_sessions;
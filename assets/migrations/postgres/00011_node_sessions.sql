@@ -0,0 +1,17 @@
+-- Runs of nodes reporting lifecycle events, closed by a stop event (clean)
+-- or at the last heartbeat when the node stopped reporting (unclean)
+CREATE TABLE IF NOT EXISTS node_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    application TEXT NOT NULL,
+    version TEXT NOT NULL DEFAULT '',
+    state TEXT NOT NULL DEFAULT 'open',
+    started_at TIMESTAMPTZ NOT NULL,
+    last_heartbeat TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ,
+    heartbeats BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_sessions_node ON node_sessions(node_id, state);
+CREATE INDEX IF NOT EXISTS idx_node_sessions_state ON node_sessions(state, last_heartbeat);
+CREATE INDEX IF NOT EXISTS idx_node_sessions_app_started ON node_sessions(application, started_at);
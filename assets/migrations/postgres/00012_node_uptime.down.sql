@@ -0,0 +1,2 @@
+-- Revert node uptime periods
+DROP TABLE IF EXISTS node_uptime;
@@ -0,0 +1,2 @@
+-- Revert A2S query retries
+DROP TABLE IF EXISTS a2s_retries;
@@ -0,0 +1,12 @@
+-- Servers whose A2S query failed during a telemetry report, queried again after growing delays.
+-- attempt is the index of the delay next_at was scheduled with.
+CREATE TABLE IF NOT EXISTS a2s_retries (
+    ip TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL,
+    next_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (ip, port)
+);
+
+CREATE INDEX IF NOT EXISTS idx_a2s_retries_next ON a2s_retries(next_at);
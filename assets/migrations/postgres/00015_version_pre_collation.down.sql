@@ -0,0 +1 @@
+ALTER TABLE nodes ALTER COLUMN version_pre TYPE TEXT COLLATE "default";
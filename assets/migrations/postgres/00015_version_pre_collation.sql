@@ -0,0 +1,2 @@
+-- Prerelease keys are ordered by bytes, as in SQLite, instead of the locale collation of the database.
+ALTER TABLE nodes ALTER COLUMN version_pre TYPE TEXT COLLATE "C";
@@ -0,0 +1,2 @@
+-- Revert rollup progress by snapshot ID
+ALTER TABLE rollup_state DROP COLUMN rolled_id;
@@ -0,0 +1,2 @@
+-- Revert per-report history of node state
+DROP TABLE IF EXISTS node_snapshots;
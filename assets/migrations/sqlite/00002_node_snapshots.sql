@@ -0,0 +1,38 @@
+-- Per-report history of node state (players, map, A2S availability)
+CREATE TABLE IF NOT EXISTS node_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id INTEGER NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    seen_at DATETIME NOT NULL,
+    version TEXT,
+    map_name TEXT,
+    game_version TEXT,
+    players INTEGER,
+    max_players INTEGER,
+    online INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_snapshots_node_seen ON node_snapshots(node_id, seen_at);
+CREATE INDEX IF NOT EXISTS idx_node_snapshots_seen ON node_snapshots(seen_at);
+
+-- Times are stored in UTC from now on, older releases stored them in the local zone
+-- as text such as "2025-12-14 21:30:05.123 +0300 MSK m=+1.5", compared as text.
+-- utc_times maps each such time to its UTC form "2025-12-14 18:30:05.123 +0000 UTC":
+-- the first 19 characters are shifted back by the "+hhmm" offset that follows the optional
+-- fraction, sp is the position of the space before the offset after the 19th character.
+CREATE TEMP TABLE utc_times AS
+SELECT t, datetime(substr(t, 1, 19),
+        ((CASE substr(t, 20 + sp, 1) WHEN '-' THEN 1 ELSE -1 END)
+        * (CAST(substr(t, 21 + sp, 2) AS INTEGER) * 60 + CAST(substr(t, 23 + sp, 2) AS INTEGER)))
+        || ' minutes')
+    || substr(t, 20, sp - 1) || ' +0000 UTC' AS utc
+FROM (
+    SELECT t, instr(substr(t, 20), ' ') AS sp
+    FROM (SELECT first_seen AS t FROM nodes UNION SELECT last_seen FROM nodes)
+    WHERE t LIKE '____-__-__ __:__:__% %' AND t NOT LIKE '%+0000 UTC'
+);
+
+UPDATE nodes SET
+    first_seen = COALESCE((SELECT utc FROM utc_times WHERE utc_times.t = nodes.first_seen), first_seen),
+    last_seen  = COALESCE((SELECT utc FROM utc_times WHERE utc_times.t = nodes.last_seen), last_seen);
+
+DROP TABLE utc_times;
@@ -0,0 +1,34 @@
+-- Hourly and daily aggregates of node_snapshots for long-term trends
+CREATE TABLE IF NOT EXISTS node_stats_hourly (
+    node_id INTEGER NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    bucket DATETIME NOT NULL,
+    samples INTEGER DEFAULT 0,
+    online_samples INTEGER DEFAULT 0,
+    players_min INTEGER DEFAULT 0,
+    players_max INTEGER DEFAULT 0,
+    players_avg REAL DEFAULT 0,
+    versions INTEGER DEFAULT 0,
+    PRIMARY KEY (node_id, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_stats_hourly_bucket ON node_stats_hourly(bucket);
+
+CREATE TABLE IF NOT EXISTS node_stats_daily (
+    node_id INTEGER NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    bucket DATETIME NOT NULL,
+    samples INTEGER DEFAULT 0,
+    online_samples INTEGER DEFAULT 0,
+    players_min INTEGER DEFAULT 0,
+    players_max INTEGER DEFAULT 0,
+    players_avg REAL DEFAULT 0,
+    versions INTEGER DEFAULT 0,
+    PRIMARY KEY (node_id, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_stats_daily_bucket ON node_stats_daily(bucket);
+
+-- Rollup progress: raw snapshots before rolled_until are already aggregated
+CREATE TABLE IF NOT EXISTS rollup_state (
+    name TEXT PRIMARY KEY,
+    rolled_until DATETIME
+);
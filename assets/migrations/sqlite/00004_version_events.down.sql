@@ -0,0 +1,2 @@
+-- Revert version transitions log
+DROP TABLE IF EXISTS version_events;
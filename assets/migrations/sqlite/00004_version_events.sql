@@ -0,0 +1,16 @@
+-- Version transitions performed by nodes, old_version is blank for first appearance
+CREATE TABLE IF NOT EXISTS version_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id INTEGER NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    application TEXT,
+    old_version TEXT,
+    new_version TEXT,
+    changed_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_version_events_app_changed ON version_events(application, changed_at);
+CREATE INDEX IF NOT EXISTS idx_version_events_node ON version_events(node_id);
+
+-- Seed current versions of already known nodes
+INSERT INTO version_events (node_id, application, old_version, new_version, changed_at)
+SELECT id, application, '', version, first_seen FROM nodes;
@@ -0,0 +1,4 @@
+-- Soft-delete tombstones, deleted nodes are kept until purged by retention
+ALTER TABLE nodes ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_nodes_deleted_at ON nodes(deleted_at);
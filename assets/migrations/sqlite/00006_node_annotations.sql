@@ -0,0 +1,15 @@
+-- Admin annotations of nodes: free-text notes, owner contact and tags
+CREATE TABLE IF NOT EXISTS node_annotations (
+    node_id INTEGER PRIMARY KEY REFERENCES nodes(id) ON DELETE CASCADE,
+    notes TEXT,
+    owner_contact TEXT,
+    updated_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS node_tags (
+    node_id INTEGER NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (node_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_tags_tag ON node_tags(tag);
@@ -0,0 +1,8 @@
+-- Distinct nodes reporting per application and UTC day, source of DAU/WAU/MAU.
+-- Rows outlive purged nodes so activity history stays complete.
+CREATE TABLE IF NOT EXISTS node_activity (
+    day DATETIME NOT NULL,
+    application TEXT NOT NULL,
+    node_id INTEGER NOT NULL,
+    PRIMARY KEY (application, day, node_id)
+);
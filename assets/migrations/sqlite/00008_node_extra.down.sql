@@ -0,0 +1,2 @@
+-- Revert custom telemetry fields
+DROP TABLE IF EXISTS node_extra;
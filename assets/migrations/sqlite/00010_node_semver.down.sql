@@ -0,0 +1,6 @@
+-- Revert sortable semantic version components
+DROP INDEX IF EXISTS idx_nodes_semver;
+ALTER TABLE nodes DROP COLUMN version_pre;
+ALTER TABLE nodes DROP COLUMN version_patch;
+ALTER TABLE nodes DROP COLUMN version_minor;
+ALTER TABLE nodes DROP COLUMN version_major;
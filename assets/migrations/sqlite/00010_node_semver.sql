@@ -0,0 +1,8 @@
+-- Sortable semantic version components, NULL for nodes reporting no or an invalid version.
+-- version_pre holds a sortable prerelease key, '~' for releases. Existing rows are filled on startup.
+ALTER TABLE nodes ADD COLUMN version_major INTEGER;
+ALTER TABLE nodes ADD COLUMN version_minor INTEGER;
+ALTER TABLE nodes ADD COLUMN version_patch INTEGER;
+ALTER TABLE nodes ADD COLUMN version_pre TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_nodes_semver ON nodes(version_major, version_minor, version_patch, version_pre);
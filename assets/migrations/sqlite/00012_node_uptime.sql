@@ -0,0 +1,12 @@
+-- Periods a node reported within its expected heartbeat interval, a new row starts after an outage.
+-- ended_at is the last report plus the interval, when the next report is due.
+CREATE TABLE IF NOT EXISTS node_uptime (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id INTEGER NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    started_at DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL,
+    ended_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_uptime_node_started ON node_uptime(node_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_node_uptime_ended ON node_uptime(ended_at);
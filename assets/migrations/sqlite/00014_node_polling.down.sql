@@ -0,0 +1,4 @@
+-- Revert live A2S state of nodes
+DROP INDEX IF EXISTS idx_nodes_queried;
+ALTER TABLE nodes DROP COLUMN queried_at;
+ALTER TABLE nodes DROP COLUMN online;
@@ -0,0 +1,8 @@
+-- Live A2S state of nodes, kept up to date by background polling.
+-- online is whether the last A2S query of the server succeeded, queried_at when it was queried last.
+ALTER TABLE nodes ADD COLUMN online INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN queried_at DATETIME;
+
+UPDATE nodes SET online = 1 WHERE COALESCE(server_name, '') <> '';
+
+CREATE INDEX IF NOT EXISTS idx_nodes_queried ON nodes(queried_at);
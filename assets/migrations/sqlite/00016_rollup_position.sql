@@ -0,0 +1,3 @@
+-- Rollup progress is tracked by the last aggregated snapshot ID instead of time,
+-- so snapshots written late are aggregated as well. NULL marks levels rolled up by time.
+ALTER TABLE rollup_state ADD COLUMN rolled_id INTEGER;
@@ -17,6 +17,7 @@ import (
 	"github.com/woozymasta/zenit/internal/logger"
 	"github.com/woozymasta/zenit/internal/maintenance"
 	"github.com/woozymasta/zenit/internal/server"
+	"github.com/woozymasta/zenit/internal/spool"
 	"github.com/woozymasta/zenit/internal/storage"
 	"github.com/woozymasta/zenit/internal/vars"
 )
@@ -25,9 +26,14 @@ func main() {
 	cfg := config.Parse()
 
 	logger.Setup(cfg.Logger)
+	dbName := cfg.Storage.Path
+	if cfg.Storage.DSN != "" {
+		dbName = "postgres" // do not leak credentials from DSN
+	}
+
 	log.Info().
 		Strs("apps", cfg.Server.AllowedApps).
-		Str("db", cfg.Storage.Path).
+		Str("db", dbName).
 		Str("mmdb", cfg.GeoIP.Path).
 		Str("version", vars.Version).
 		Time("build", vars.BuildTime).
@@ -52,7 +58,18 @@ func main() {
 	}
 
 	// Database
-	store, err := storage.New(cfg.Storage.Path)
+	newSQLite, newPostgres := storage.NewSQLite, storage.NewPostgres
+	if cfg.Storage.MigrateStatus || cfg.Storage.MigrateTo != "" {
+		// Migration modes manage the schema themselves
+		newSQLite, newPostgres = storage.OpenSQLite, storage.OpenPostgres
+	}
+
+	var store storage.Store
+	if cfg.Storage.DSN != "" {
+		store, err = newPostgres(cfg.Storage.DSN)
+	} else {
+		store, err = newSQLite(cfg.Storage.Path)
+	}
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize database")
 	}
@@ -70,8 +87,22 @@ func main() {
 		return
 	}
 
+	// Telemetry spool
+	var queueSpool *spool.Spool
+	if cfg.Queue.SpoolDir != "" {
+		queueSpool, err = spool.Open(cfg.Queue.SpoolDir, cfg.Queue.SpoolMaxSize, cfg.Queue.SpoolDrop == config.SpoolDropOld)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to open telemetry spool")
+		}
+
+		log.Info().
+			Str("dir", cfg.Queue.SpoolDir).
+			Int64("pending", queueSpool.Pending()).
+			Msg("Telemetry spool opened")
+	}
+
 	// Init server
-	srvHandler := server.New(store, geoProvider, cfg)
+	srvHandler := server.New(store, geoProvider, queueSpool, cfg)
 
 	// Background queue
 	srvHandler.StartWorkers()
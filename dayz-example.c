@@ -5,6 +5,7 @@ class SomeClass
   static const string MOD_VERSION = "1.2.5";
   static const string TELEMETRY_URL = "https://zenit.woozymasta.ru";
 	static const int TELEMETRY_DELAY = 600000; // 10-20 min
+	static const int TELEMETRY_HEARTBEAT = 600000; // 10 min, above --rate-limit-soft and below --session-timeout
   protected static bool s_TelemetrySend;
 
 	/**
@@ -18,15 +19,34 @@ class SomeClass
 		if (!disableTelemetry && !s_TelemetrySend) {
 			s_TelemetrySend = true;
 			int delay = Math.RandomInt(TELEMETRY_DELAY, TELEMETRY_DELAY * 2);
-			g_Game.GetCallQueue(CALL_CATEGORY_SYSTEM).CallLater(SendTelemetry, delay, false);
+			g_Game.GetCallQueue(CALL_CATEGORY_SYSTEM).CallLater(SendTelemetry, delay, false, "start");
+			g_Game.GetCallQueue(CALL_CATEGORY_SYSTEM).CallLater(SendHeartbeat, delay + TELEMETRY_HEARTBEAT, true);
 		}
 #endif
 	}
 
+	/**
+	    \brief Closes the telemetry session on a clean shutdown.
+	*/
+	void OnMissionFinish()
+	{
+		if (s_TelemetrySend)
+			SendTelemetry("stop");
+	}
+
+	/**
+	    \brief Periodic heartbeat, a session without heartbeats is counted as a crash
+	*/
+	protected void SendHeartbeat()
+	{
+		SendTelemetry("heartbeat");
+	}
+
 	/**
 	    \brief Telemetry sender
+	    \param event lifecycle event: start, heartbeat or stop
 	*/
-	protected void SendTelemetry()
+	protected void SendTelemetry(string event)
 	{
 		RestApi api = GetRestApi();
 		if (!api)
@@ -43,8 +63,8 @@ class SomeClass
 		}
 
 		string body = string.Format(
-		                  "{\"application\":\"%1\",\"version\":\"%2\",\"type\":\"steam\",\"port\":%3}",
-		                  MOD_NAME, MOD_VERSION, g_Game.ServerConfigGetInt("steamQueryPort"));
+		                  "{\"application\":\"%1\",\"version\":\"%2\",\"type\":\"steam\",\"port\":%3,\"event\":\"%4\"}",
+		                  MOD_NAME, MOD_VERSION, g_Game.ServerConfigGetInt("steamQueryPort"), event);
 
 		ctx.SetHeader("application/json");
 		ctx.POST(null, "/api/telemetry", body);
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSecretMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+	data := "# signed applications\nMetricZ:from-file\n\n  Other:other-secret  \n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := Signing{SecretsFile: path, Secrets: []string{"MetricZ:from-flag", "Third:third"}}
+	got, err := s.SecretMap()
+	if err != nil {
+		t.Fatalf("SecretMap: %v", err)
+	}
+	want := map[string]string{"MetricZ": "from-flag", "Other": "other-secret", "Third": "third"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SecretMap() = %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("no separator\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SecretMap(); err == nil {
+		t.Error("SecretMap() accepted a line without App:secret")
+	}
+
+	s.SecretsFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := s.SecretMap(); err == nil {
+		t.Error("SecretMap() accepted a missing file")
+	}
+}
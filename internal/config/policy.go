@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AppPolicy holds ingestion rules of a single application read from the policy file.
+// Unset fields fall back to the global flags.
+type AppPolicy struct {
+	// UserAgent is the expected User-Agent, nil keeps --expect-user-agent.
+	UserAgent *string `yaml:"user_agent"`
+
+	// IgnoreUserAgent disables User-Agent validation, nil keeps --ignore-user-agent.
+	IgnoreUserAgent *bool `yaml:"ignore_user_agent"`
+
+	// RejectInvalidVersion drops reports whose version is not a semantic version, nil keeps --reject-invalid-version.
+	RejectInvalidVersion *bool `yaml:"reject_invalid_version"`
+
+	// VersionPattern is the compiled Version, nil if any version is allowed.
+	VersionPattern *regexp.Regexp `yaml:"-"`
+
+	// Version is a regular expression the reported version must match.
+	Version string `yaml:"version"`
+
+	// Types lists allowed report types, an omitted type is "generic". Empty allows any type.
+	Types []string `yaml:"types"`
+
+	// SoftLimit overrides --rate-limit-soft for reports of the application.
+	SoftLimit time.Duration `yaml:"soft_limit"`
+
+	// HeartbeatInterval overrides --heartbeat-interval, the expected interval between reports of a node.
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+
+	// DefaultPort is used when a report has no port, zero keeps 27016.
+	DefaultPort int `yaml:"default_port"`
+
+	// RequireA2S queries every report regardless of its type and drops it if the query fails.
+	RequireA2S bool `yaml:"require_a2s"`
+}
+
+// policyFile is the layout of the policy file.
+type policyFile struct {
+	Applications map[string]*AppPolicy `yaml:"applications"`
+}
+
+// LoadPolicies reads per-application policies from a YAML file, keyed by application name.
+func LoadPolicies(path string) (map[string]*AppPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file policyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse policy file %s: %w", path, err)
+	}
+
+	for app, p := range file.Applications {
+		if p == nil {
+			p = &AppPolicy{}
+			file.Applications[app] = p
+		}
+
+		if p.DefaultPort < 0 || p.DefaultPort > 65535 {
+			return nil, fmt.Errorf("policy of %s: invalid default_port %d", app, p.DefaultPort)
+		}
+		if p.SoftLimit < 0 {
+			return nil, fmt.Errorf("policy of %s: negative soft_limit", app)
+		}
+		if p.HeartbeatInterval < 0 {
+			return nil, fmt.Errorf("policy of %s: negative heartbeat_interval", app)
+		}
+		if p.Version != "" {
+			if p.VersionPattern, err = regexp.Compile(p.Version); err != nil {
+				return nil, fmt.Errorf("policy of %s: invalid version pattern: %w", app, err)
+			}
+		}
+	}
+
+	return file.Applications, nil
+}
+
+// checkHeartbeats returns an error if the heartbeat interval of the global rules or any application policy
+// is shorter than its soft limit, the soft limit would skip heartbeats and count the nodes as down between them.
+func checkHeartbeats(heartbeat, softLimit time.Duration, policies map[string]*AppPolicy) error {
+	if heartbeat > 0 && heartbeat < softLimit {
+		return fmt.Errorf("heartbeat interval %s is shorter than soft limit %s", heartbeat, softLimit)
+	}
+
+	for app, p := range policies {
+		h, soft := heartbeat, softLimit
+		if p.HeartbeatInterval != 0 {
+			h = p.HeartbeatInterval
+		}
+		if p.SoftLimit != 0 {
+			soft = p.SoftLimit
+		}
+
+		if h > 0 && h < soft {
+			return fmt.Errorf("policy of %s: heartbeat_interval %s is shorter than soft_limit %s", app, h, soft)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckHeartbeats(t *testing.T) {
+	tests := []struct {
+		name      string
+		policies  map[string]*AppPolicy
+		heartbeat time.Duration
+		softLimit time.Duration
+		wantErr   bool
+	}{
+		{name: "disabled", heartbeat: 0, softLimit: 5 * time.Minute},
+		{name: "equal", heartbeat: 5 * time.Minute, softLimit: 5 * time.Minute},
+		{name: "longer", heartbeat: 10 * time.Minute, softLimit: 5 * time.Minute},
+		{name: "shorter", heartbeat: time.Minute, softLimit: 5 * time.Minute, wantErr: true},
+		{
+			name:      "policy shortens interval",
+			heartbeat: 10 * time.Minute, softLimit: 5 * time.Minute,
+			policies: map[string]*AppPolicy{"app": {HeartbeatInterval: time.Minute}},
+			wantErr:  true,
+		},
+		{
+			name:      "policy raises soft limit",
+			heartbeat: 10 * time.Minute, softLimit: 5 * time.Minute,
+			policies: map[string]*AppPolicy{"app": {SoftLimit: 15 * time.Minute}},
+			wantErr:  true,
+		},
+		{
+			name:      "policy lowers soft limit",
+			heartbeat: 0, softLimit: 5 * time.Minute,
+			policies: map[string]*AppPolicy{"app": {HeartbeatInterval: time.Minute, SoftLimit: 30 * time.Second}},
+		},
+		{
+			name:      "global shorter than global soft limit",
+			heartbeat: time.Minute, softLimit: 5 * time.Minute,
+			policies: map[string]*AppPolicy{"app": {SoftLimit: 30 * time.Second}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkHeartbeats(tt.heartbeat, tt.softLimit, tt.policies)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkHeartbeats() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
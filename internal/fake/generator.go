@@ -13,7 +13,7 @@ import (
 
 // GenerateData populates the storage with a specified number of randomized node records.
 // It simulates various game maps, versions, countries, and player counts.
-func GenerateData(store *storage.Repository, count int) {
+func GenerateData(store storage.Store, count int) {
 	apps := []string{"MetricZ", "DayZMod", "AdminTool"}
 	maps := []string{"chernarusplus", "livonia", "namalsk", "takistan", "enoch", "sakhal", "deerisle"}
 	osTypes := []string{"Windows", "Linux"}
@@ -2,6 +2,7 @@
 package maintenance
 
 import (
+	"os"
 	"sync"
 	"time"
 
@@ -10,11 +11,12 @@ import (
 	"github.com/woozymasta/zenit/internal/game"
 	"github.com/woozymasta/zenit/internal/models"
 	"github.com/woozymasta/zenit/internal/storage"
+	"github.com/woozymasta/zenit/internal/transfer"
 )
 
 // Run checks if any maintenance flags are set and executes the corresponding tasks.
 // Returns true if a maintenance task was executed (indicating the program should exit).
-func Run(cfg *config.Config, store *storage.Repository) bool {
+func Run(cfg *config.Config, store storage.Store) bool {
 	// Prune Empty
 	if cfg.Storage.PruneEmpty != "" {
 		appName := parseAppName(cfg.Storage.PruneEmpty)
@@ -30,6 +32,51 @@ func Run(cfg *config.Config, store *storage.Repository) bool {
 		return true
 	}
 
+	// Migrations
+	if cfg.Storage.MigrateStatus {
+		migrationStatus(store)
+		return true
+	}
+
+	if cfg.Storage.MigrateTo != "" {
+		log.Info().
+			Str("version", cfg.Storage.MigrateTo).
+			Bool("dry_run", cfg.Storage.MigrateDryRun).
+			Msg("Migrating database schema...")
+
+		if err := store.MigrateTo(cfg.Storage.MigrateTo, cfg.Storage.MigrateDryRun); err != nil {
+			log.Error().Err(err).Msg("Failed to migrate database")
+		} else {
+			log.Info().Msg("Migration finished")
+		}
+
+		return true
+	}
+
+	// Backup
+	if cfg.Storage.Backup != "" {
+		log.Info().Str("path", cfg.Storage.Backup).Msg("Writing database backup...")
+
+		if err := store.Backup(cfg.Storage.Backup); err != nil {
+			log.Error().Err(err).Msg("Failed to backup database")
+		} else {
+			log.Info().Msg("Backup finished")
+		}
+
+		return true
+	}
+
+	// Export and import
+	if cfg.Storage.Export != "" {
+		exportNodes(store, cfg.Storage.Export)
+		return true
+	}
+
+	if cfg.Storage.Import != "" {
+		importNodes(store, cfg.Storage.Import)
+		return true
+	}
+
 	// Check Inactive OR Check All
 	// Since these are mutually exclusive or run sequentially, we prioritize them logic.
 	// If both are present, we usually pick one or run sequentially. Here we pick one.
@@ -62,13 +109,112 @@ func Run(cfg *config.Config, store *storage.Repository) bool {
 		return true
 	}
 
-	log.Info().Int("count", len(nodes)).Msgf("Starting '%s' task with 10 workers...", taskName)
-	runWorkerPool(nodes, store, cfg.A2S)
+	workers := max(cfg.Storage.CheckWorkers, 1)
+	log.Info().Int("count", len(nodes)).Msgf("Starting '%s' task with %d workers...", taskName, workers)
+	runWorkerPool(nodes, store, cfg.A2S, workers)
 	log.Info().Msg("Maintenance task completed")
 
 	return true
 }
 
+// migrationStatus logs every known schema migration with its state.
+func migrationStatus(store storage.Store) {
+	states, err := store.MigrationStatus()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read migration status")
+		return
+	}
+
+	pending := 0
+	for _, st := range states {
+		state := "pending"
+		switch {
+		case st.Unknown:
+			state = "unknown"
+		case st.Modified:
+			state = "modified"
+		case st.Applied:
+			state = "applied"
+		default:
+			pending++
+		}
+
+		ev := log.Info().
+			Str("version", st.Version).
+			Str("state", state).
+			Bool("reversible", st.Reversible)
+		if st.Applied {
+			ev = ev.Time("applied_at", st.AppliedAt)
+		}
+		ev.Msg("Migration")
+	}
+
+	log.Info().Int("total", len(states)).Int("pending", pending).Msg("Migration status")
+}
+
+// exportNodes writes all nodes into a file, the format is detected from its extension.
+func exportNodes(store storage.Store, path string) {
+	format, err := transfer.FormatFromPath(path)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to export nodes")
+		return
+	}
+
+	log.Info().Str("path", path).Str("format", format).Msg("Exporting nodes...")
+
+	f, err := os.Create(path) // #nosec G304 -- path is given by the operator
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create export file")
+		return
+	}
+
+	count, err := transfer.Export(f, format, store)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("exported", count).Msg("Failed to export nodes")
+		return
+	}
+
+	log.Info().Int64("exported", count).Msg("Export finished")
+}
+
+// importNodes merges nodes from a file into the database, the format is detected from its extension.
+func importNodes(store storage.Store, path string) {
+	format, err := transfer.FormatFromPath(path)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to import nodes")
+		return
+	}
+
+	log.Info().Str("path", path).Str("format", format).Msg("Importing nodes...")
+
+	f, err := os.Open(path) // #nosec G304 -- path is given by the operator
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open import file")
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	res, err := transfer.Import(f, format, store)
+	if err != nil {
+		// Batches merged before the error are kept
+		log.Error().Err(err).
+			Int64("inserted", res.Inserted).
+			Int64("updated", res.Updated).
+			Int64("skipped", res.Skipped).
+			Msg("Failed to import nodes")
+		return
+	}
+
+	log.Info().
+		Int64("inserted", res.Inserted).
+		Int64("updated", res.Updated).
+		Int64("skipped", res.Skipped).
+		Msg("Import finished")
+}
+
 // parseAppName handles the optional value logic.
 // If the flag is provided without a value (or with default), it might come as "AnyApp".
 // We convert "AnyApp" to empty string for the storage layer (which means "no filter").
@@ -80,8 +226,7 @@ func parseAppName(input string) string {
 	return input
 }
 
-func runWorkerPool(nodes []models.Node, store *storage.Repository, a2sOpts config.A2S) {
-	const workers = 10
+func runWorkerPool(nodes []models.Node, store storage.Store, a2sOpts config.A2S, workers int) {
 	jobs := make(chan models.Node, len(nodes))
 	var wg sync.WaitGroup
 
@@ -105,7 +250,7 @@ func runWorkerPool(nodes []models.Node, store *storage.Repository, a2sOpts confi
 	wg.Wait()
 }
 
-func processNode(node models.Node, store *storage.Repository, a2sOpts config.A2S) {
+func processNode(node models.Node, store storage.Store, a2sOpts config.A2S) {
 	logCtx := log.With().
 		Str("app", node.Application).
 		Str("ip", node.IP).
@@ -115,7 +260,7 @@ func processNode(node models.Node, store *storage.Repository, a2sOpts config.A2S
 	// 1. Port validation (1000 - 65536)
 	// We use 65535 as standard max port, but requirement mentioned 65536, adjusted to standard range.
 	if node.Port <= 1000 || node.Port > 65535 {
-		logCtx.Debug().Msg("Invalid port, deleting node")
+		logCtx.Debug().Msg("Invalid port, moving node to trash")
 		if err := store.DeleteNode(node.Application, node.IP, node.Port); err != nil {
 			logCtx.Error().Err(err).Msg("Failed to delete invalid node")
 		}
@@ -125,8 +270,8 @@ func processNode(node models.Node, store *storage.Repository, a2sOpts config.A2S
 	// 2. A2S Query
 	info, err := game.QueryServer(node.IP, node.Port, a2sOpts)
 	if err != nil {
-		// Check failed -> Delete
-		logCtx.Debug().Err(err).Msg("Server unreachable, deleting node")
+		// Check failed -> Move to trash, restorable until purged
+		logCtx.Debug().Err(err).Msg("Server unreachable, moving node to trash")
 		if err := store.DeleteNode(node.Application, node.IP, node.Port); err != nil {
 			logCtx.Error().Err(err).Msg("Failed to delete unreachable node")
 		}
@@ -147,7 +292,13 @@ func processNode(node models.Node, store *storage.Repository, a2sOpts config.A2S
 	// UpsertNode handles the update logic
 	if err := store.UpsertNode(node); err != nil {
 		logCtx.Error().Err(err).Msg("Failed to update node")
-	} else {
-		logCtx.Trace().Msg("Node updated successfully")
+		return
 	}
+
+	if err := store.AddSnapshot(node, true); err != nil {
+		logCtx.Error().Err(err).Msg("Failed to save node snapshot")
+		return
+	}
+
+	logCtx.Trace().Msg("Node updated successfully")
 }
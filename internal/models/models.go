@@ -3,30 +3,265 @@ package models
 
 import "time"
 
+// Lifecycle events a telemetry report may carry, reports without an event do not take part in sessions.
+const (
+	// EventStart opens a new session of the node, closing an open one as unclean.
+	EventStart = "start"
+
+	// EventHeartbeat extends the open session of the node, or opens one if it has none.
+	EventHeartbeat = "heartbeat"
+
+	// EventStop closes the open session of the node as clean.
+	EventStop = "stop"
+)
+
+// Session states.
+const (
+	// SessionOpen is a running session that has not stopped or timed out yet.
+	SessionOpen = "open"
+
+	// SessionClean is a session closed by a stop event.
+	SessionClean = "clean"
+
+	// SessionUnclean is a session that timed out without heartbeats or was followed by a new start,
+	// its end is the last heartbeat.
+	SessionUnclean = "unclean"
+)
+
 // TelemetryRequest represents the payload sent by the game client/mod.
 type TelemetryRequest struct {
 	Application string `json:"application"`
 	Type        string `json:"type,omitempty"`
 	Version     string `json:"version,omitempty"`
+	Event       string `json:"event,omitempty"`
 	Port        int    `json:"port"`
+
+	// Extra holds optional custom fields with string, number or bool values.
+	Extra map[string]interface{} `json:"extra,omitempty"`
+}
+
+// TelemetryBatchRequest represents the payload sent by a game server running several tracked mods.
+// Port, Type and Event apply to every entry, Port, Type and Event of the entries are ignored.
+type TelemetryBatchRequest struct {
+	Type         string             `json:"type,omitempty"`
+	Event        string             `json:"event,omitempty"`
+	Applications []TelemetryRequest `json:"applications"`
+	Port         int                `json:"port"`
 }
 
 // Node represents a registered game server stored in the database.
 type Node struct {
-	FirstSeen   time.Time `json:"first_seen"`
-	LastSeen    time.Time `json:"last_seen"`
-	Application string    `json:"application"`
-	Type        string    `json:"type"`
-	IP          string    `json:"ip"`
-	CountryCode string    `json:"country_code"`
+	FirstSeen   time.Time              `json:"first_seen"`
+	LastSeen    time.Time              `json:"last_seen"`
+	DeletedAt   *time.Time             `json:"deleted_at,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Application string                 `json:"application"`
+	Type        string                 `json:"type"`
+	IP          string                 `json:"ip"`
+	CountryCode string                 `json:"country_code"`
+	Version     string                 `json:"version"`
+	ServerName  string                 `json:"server_name"`
+	MapName     string                 `json:"map_name"`
+	GameVersion string                 `json:"game_version"`
+	GameName    string                 `json:"game_name"`
+	ServerOS    string                 `json:"server_os"`
+	ID          int64                  `json:"id"`
+	Port        int                    `json:"port"`
+	Count       int64                  `json:"count"`
+	Players     byte                   `json:"players"`
+	MaxPlayers  byte                   `json:"max_players"`
+	Verified    bool                   `json:"verified"`
+}
+
+// NodeAnnotation represents information about a node maintained by administrators.
+type NodeAnnotation struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	Notes     string    `json:"notes"`
+	Contact   string    `json:"owner_contact"`
+	Tags      []string  `json:"tags"`
+	NodeID    int64     `json:"node_id"`
+}
+
+// NodePage represents a single page of a filtered and sorted node listing.
+// NextCursor requests the following page, it is empty on the last page.
+type NodePage struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	Nodes      []Node `json:"nodes"`
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+}
+
+// Summary represents aggregated statistics of nodes matching a filter.
+// Online nodes are those whose last A2S query succeeded, servers reporting
+// several applications are counted once in UniqueServers and Players.
+type Summary struct {
+	Applications  []GroupCount            `json:"applications"`
+	Countries     []GroupCount            `json:"countries"`
+	OS            []GroupCount            `json:"os"`
+	Versions      []GroupCount            `json:"versions"`
+	Maps          []GroupCount            `json:"maps"`
+	Timeline      []TimeCount             `json:"timeline"`
+	TopServers    []Node                  `json:"top_servers"`
+	Extra         map[string][]GroupCount `json:"extra,omitempty"`
+	Total         int64                   `json:"total"`
+	Online        int64                   `json:"online"`
+	Offline       int64                   `json:"offline"`
+	UniqueServers int64                   `json:"unique_servers"`
+	UniqueHosts   int64                   `json:"unique_hosts"`
+	Players       int64                   `json:"players"`
+}
+
+// GroupCount represents the number of nodes sharing the same value of a field.
+type GroupCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// TimeCount represents the number of nodes last seen within a time bucket.
+type TimeCount struct {
+	Bucket time.Time `json:"bucket"`
+	Count  int64     `json:"count"`
+}
+
+// NodeSnapshot represents the state of a node captured at a single report or re-check.
+type NodeSnapshot struct {
+	Time        time.Time `json:"time"`
 	Version     string    `json:"version"`
-	ServerName  string    `json:"server_name"`
 	MapName     string    `json:"map_name"`
 	GameVersion string    `json:"game_version"`
-	GameName    string    `json:"game_name"`
-	ServerOS    string    `json:"server_os"`
-	Port        int       `json:"port"`
-	Count       int64     `json:"count"`
 	Players     byte      `json:"players"`
 	MaxPlayers  byte      `json:"max_players"`
+	Online      bool      `json:"online"`
+}
+
+// NodeStat represents node history aggregated over a single hourly or daily bucket.
+type NodeStat struct {
+	Bucket        time.Time `json:"bucket"`
+	PlayersAvg    float64   `json:"players_avg"`
+	OnlineRatio   float64   `json:"online_ratio"`
+	Samples       int64     `json:"samples"`
+	OnlineSamples int64     `json:"online_samples"`
+	Versions      int       `json:"versions"`
+	PlayersMin    byte      `json:"players_min"`
+	PlayersMax    byte      `json:"players_max"`
+}
+
+// VersionEvent represents a version change (upgrade or downgrade) performed by a node.
+// OldVersion is empty for the first report of a node.
+type VersionEvent struct {
+	Time        time.Time `json:"time"`
+	Application string    `json:"application"`
+	IP          string    `json:"ip"`
+	OldVersion  string    `json:"old_version"`
+	NewVersion  string    `json:"new_version"`
+	NodeID      int64     `json:"node_id"`
+	Port        int       `json:"port"`
+}
+
+// ActivityDay represents the number of distinct nodes active on a day (DAU)
+// and within the 7 (WAU) and 30 (MAU) days ending on it.
+type ActivityDay struct {
+	Day time.Time `json:"day"`
+	DAU int64     `json:"dau"`
+	WAU int64     `json:"wau"`
+	MAU int64     `json:"mau"`
+}
+
+// AppActivity represents active node counts of an application.
+// DAU, WAU, MAU and Stickiness (DAU/MAU) describe the last day of Days.
+type AppActivity struct {
+	Application string        `json:"application"`
+	Days        []ActivityDay `json:"days"`
+	Stickiness  float64       `json:"stickiness"`
+	DAU         int64         `json:"dau"`
+	WAU         int64         `json:"wau"`
+	MAU         int64         `json:"mau"`
+}
+
+// Session represents a single run of a node reporting lifecycle events, from its start event
+// to its stop event (clean) or to its last heartbeat before it timed out (unclean).
+// Duration is in seconds, up to the last heartbeat for open sessions.
+type Session struct {
+	StartedAt     time.Time  `json:"started_at"`
+	LastHeartbeat time.Time  `json:"last_heartbeat"`
+	EndedAt       *time.Time `json:"ended_at,omitempty"`
+	Application   string     `json:"application"`
+	Version       string     `json:"version"`
+	State         string     `json:"state"`
+	ID            int64      `json:"id"`
+	NodeID        int64      `json:"node_id"`
+	Heartbeats    int64      `json:"heartbeats"`
+	Duration      int64      `json:"duration"`
+}
+
+// VersionSessions represents sessions of an application version aggregated over a time range.
+// CrashRate is the share of unclean sessions among closed ones, uptimes are in seconds,
+// AvgUptime and MaxUptime cover closed sessions only.
+type VersionSessions struct {
+	Application string  `json:"application"`
+	Version     string  `json:"version"`
+	CrashRate   float64 `json:"crash_rate"`
+	Sessions    int64   `json:"sessions"`
+	Open        int64   `json:"open"`
+	Clean       int64   `json:"clean"`
+	Unclean     int64   `json:"unclean"`
+	AvgUptime   int64   `json:"avg_uptime"`
+	MaxUptime   int64   `json:"max_uptime"`
+	TotalUptime int64   `json:"total_uptime"`
+}
+
+// Uptime represents availability of a node derived from the gaps between its reports,
+// measured from the start of the range or from its first tracked report if that is later.
+// A node is up until its next report is due and an outage starts when a report is missed twice.
+// Durations are in seconds, CurrentStreak is zero while the node is down.
+type Uptime struct {
+	From          time.Time  `json:"from"`
+	To            time.Time  `json:"to"`
+	LastSeen      *time.Time `json:"last_seen,omitempty"`
+	Availability  float64    `json:"availability"`
+	UpTime        int64      `json:"uptime"`
+	Outages       int64      `json:"outages"`
+	LongestOutage int64      `json:"longest_outage"`
+	CurrentStreak int64      `json:"current_streak"`
+	Tracked       bool       `json:"tracked"`
+	Up            bool       `json:"up"`
+}
+
+// VersionUptime represents availability of tracked nodes currently running an application version.
+// Availability is the average of the nodes, LongestOutage the longest of any node, in seconds.
+type VersionUptime struct {
+	Application   string  `json:"application"`
+	Version       string  `json:"version"`
+	Availability  float64 `json:"availability"`
+	Nodes         int64   `json:"nodes"`
+	Up            int64   `json:"up"`
+	Outages       int64   `json:"outages"`
+	LongestOutage int64   `json:"longest_outage"`
+}
+
+// QueueStats represents the live state of the telemetry queue and its workers.
+// Processed and Dropped count jobs since start, a job holds one single or batch report.
+type QueueStats struct {
+	StartedAt    time.Time `json:"started_at"`
+	Depth        int       `json:"depth"`
+	Capacity     int       `json:"capacity"`
+	Workers      int64     `json:"workers"`
+	MinWorkers   int       `json:"min_workers"`
+	MaxWorkers   int       `json:"max_workers"`
+	BusyWorkers  int64     `json:"busy_workers"`
+	Processed    uint64    `json:"processed"`
+	Dropped      uint64    `json:"dropped"`
+	SpoolPending int64     `json:"spool_pending"`
+	Spool        bool      `json:"spool"`
+}
+
+// A2SRetry represents a game server whose A2S query failed during a telemetry report
+// and is scheduled to be queried again. Attempt is the index of the retry delay NextAt was scheduled with.
+type A2SRetry struct {
+	CreatedAt time.Time `json:"created_at"`
+	NextAt    time.Time `json:"next_at"`
+	IP        string    `json:"ip"`
+	Port      int       `json:"port"`
+	Attempt   int       `json:"attempt"`
 }
@@ -0,0 +1,151 @@
+// Package semver parses, normalizes and orders semantic versions reported by applications.
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ReleaseKey is the sortable prerelease key of a release, it sorts after every prerelease key.
+const ReleaseKey = "~"
+
+// Version is a parsed semantic version, build metadata does not take part in ordering.
+type Version struct {
+	Build string
+	Pre   []string
+	Major int64
+	Minor int64
+	Patch int64
+}
+
+// Parse parses a semantic version. The input is lenient: surrounding spaces and a "v" prefix
+// are dropped and missing minor and patch components are zero, so "v1.2" is "1.2.0".
+func Parse(s string) (Version, error) {
+	var v Version
+
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if s == "" {
+		return v, errors.New("empty version")
+	}
+
+	var hasBuild bool
+	s, v.Build, hasBuild = strings.Cut(s, "+")
+	core, pre, hasPre := strings.Cut(s, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("version %q has more than 3 components", s)
+	}
+	nums := [3]*int64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := parseNumber(part)
+		if err != nil {
+			return v, fmt.Errorf("version component %q: %w", part, err)
+		}
+		*nums[i] = n
+	}
+
+	if hasPre {
+		v.Pre = strings.Split(pre, ".")
+		for _, id := range v.Pre {
+			if !validIdentifier(id) {
+				return v, fmt.Errorf("invalid prerelease identifier %q", id)
+			}
+		}
+	}
+	if hasBuild {
+		for _, id := range strings.Split(v.Build, ".") {
+			if !validIdentifier(id) {
+				return v, fmt.Errorf("invalid build identifier %q", id)
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// Normalize returns the canonical form of a version and whether it is a valid semantic version.
+// Invalid versions are returned trimmed but otherwise unchanged.
+func Normalize(s string) (string, bool) {
+	v, err := Parse(s)
+	if err != nil {
+		return strings.TrimSpace(s), false
+	}
+
+	return v.String(), true
+}
+
+// String returns the canonical form MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD].
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+
+	return s
+}
+
+// PreKey returns a key whose byte order matches semantic version precedence of prereleases:
+// numeric identifiers are zero padded and sort before alphanumeric ones,
+// and a release (ReleaseKey) sorts after all its prereleases.
+func (v Version) PreKey() string {
+	if len(v.Pre) == 0 {
+		return ReleaseKey
+	}
+
+	keys := make([]string, len(v.Pre))
+	for i, id := range v.Pre {
+		if n, err := parseNumber(id); err == nil {
+			keys[i] = fmt.Sprintf("0%019d", n)
+		} else {
+			keys[i] = "1" + id
+		}
+	}
+
+	// A space sorts before every identifier character, so a shorter set of identifiers comes first
+	return strings.Join(keys, " ")
+}
+
+// Compare returns -1, 0 or +1 depending on whether a has lower, equal or higher precedence than b.
+func Compare(a, b Version) int {
+	for _, d := range [][2]int64{{a.Major, b.Major}, {a.Minor, b.Minor}, {a.Patch, b.Patch}} {
+		switch {
+		case d[0] < d[1]:
+			return -1
+		case d[0] > d[1]:
+			return 1
+		}
+	}
+
+	return strings.Compare(a.PreKey(), b.PreKey())
+}
+
+// parseNumber parses a non-negative decimal number.
+func parseNumber(s string) (int64, error) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, errors.New("not a number")
+	}
+
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// validIdentifier reports whether id is a non-empty identifier of ASCII letters, digits and hyphens.
+func validIdentifier(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+			return false
+		}
+	}
+
+	return true
+}
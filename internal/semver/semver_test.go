@@ -0,0 +1,147 @@
+package semver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Version
+		wantErr bool
+	}{
+		{in: "1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{in: " v1.2 ", want: Version{Major: 1, Minor: 2}},
+		{in: "V7", want: Version{Major: 7}},
+		{in: "1.0.0-rc.1", want: Version{Major: 1, Pre: []string{"rc", "1"}}},
+		{in: "1.0.0-alpha-2+build.5", want: Version{Major: 1, Pre: []string{"alpha-2"}, Build: "build.5"}},
+		{in: "1.0.0+20261016", want: Version{Major: 1, Build: "20261016"}},
+		{in: "", wantErr: true},
+		{in: "v", wantErr: true},
+		{in: "1.2.3.4", wantErr: true},
+		{in: "1..3", wantErr: true},
+		{in: "1.-2.3", wantErr: true},
+		{in: "1.x.3", wantErr: true},
+		{in: "1.0.0-", wantErr: true},
+		{in: "1.0.0-rc..1", wantErr: true},
+		{in: "1.0.0-rc_1", wantErr: true},
+		{in: "1.0.0+", wantErr: true},
+		{in: "99999999999999999999.0.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %+v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  string
+		valid bool
+	}{
+		{in: "v1.2", want: "1.2.0", valid: true},
+		{in: " 1.0.0-RC.1+b ", want: "1.0.0-RC.1+b", valid: true},
+		{in: " dev build ", want: "dev build"},
+		{in: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		got, valid := Normalize(tt.in)
+		if got != tt.want || valid != tt.valid {
+			t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.in, got, valid, tt.want, tt.valid)
+		}
+	}
+}
+
+func TestPreKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "1.0.0", want: ReleaseKey},
+		{in: "1.0.0+build", want: ReleaseKey},
+		{in: "1.0.0-rc", want: "1rc"},
+		{in: "1.0.0-12", want: "00000000000000000012"},
+		{in: "1.0.0-rc.1", want: "1rc 00000000000000000001"},
+	}
+
+	for _, tt := range tests {
+		v, err := Parse(tt.in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.in, err)
+		}
+		if got := v.PreKey(); got != tt.want {
+			t.Errorf("PreKey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Ordered by precedence as in the semantic versioning specification, plus core components
+	ordered := []string{
+		"0.9.9",
+		"1.0.0-1",
+		"1.0.0-2",
+		"1.0.0-10",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+		"10.0.0",
+	}
+
+	for i, a := range ordered {
+		va, err := Parse(a)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", a, err)
+		}
+		for j, b := range ordered {
+			vb, err := Parse(b)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", b, err)
+			}
+
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := Compare(va, vb); got != want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestCompareIgnoresBuild(t *testing.T) {
+	a, _ := Parse("1.0.0+a")
+	b, _ := Parse("v1.0+b")
+	if got := Compare(a, b); got != 0 {
+		t.Errorf("Compare(1.0.0+a, v1.0+b) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/storage"
+)
+
+const (
+	// backupPrefix and backupSuffix surround the timestamp in backup file names.
+	backupPrefix = "zenit-"
+	backupSuffix = ".db"
+
+	// backupTimeFormat is the timestamp layout of backup file names, sortable by name.
+	backupTimeFormat = "20060102-150405"
+)
+
+// handleBackup streams a consistent snapshot of the SQLite database as a file download.
+// The snapshot is written to a temporary file first and removed after it is sent.
+func (s *Server) handleBackup(w http.ResponseWriter, _ *http.Request) {
+	dir, err := os.MkdirTemp("", "zenit-backup-")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create backup directory")
+		http.Error(w, "Backup Error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	name := backupName(time.Now())
+	path := filepath.Join(dir, name)
+	if err := s.storage.Backup(path); err != nil {
+		if errors.Is(err, storage.ErrBackupUnsupported) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+
+		log.Error().Err(err).Msg("Failed to backup database")
+		http.Error(w, "Backup Error", http.StatusInternalServerError)
+		return
+	}
+
+	f, err := os.Open(path) // #nosec G304 -- path is built from a temporary directory
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open backup")
+		http.Error(w, "Backup Error", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to stat backup")
+		http.Error(w, "Backup Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Large databases take longer than the server write timeout to send
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	if _, err := io.Copy(w, f); err != nil {
+		log.Warn().Err(err).Msg("Failed to send backup")
+		return
+	}
+
+	log.Info().Int64("size", info.Size()).Msg("Backup downloaded")
+}
+
+// runBackups periodically writes a backup into the backup directory and rotates old copies.
+func (s *Server) runBackups() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.backupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			s.writeBackup()
+		}
+	}
+}
+
+// writeBackup executes a single scheduled backup followed by rotation and logs its outcome.
+func (s *Server) writeBackup() {
+	start := time.Now()
+
+	if err := os.MkdirAll(s.backupDir, 0o750); err != nil {
+		log.Error().Err(err).Str("dir", s.backupDir).Msg("Failed to create backup directory")
+		return
+	}
+
+	path := filepath.Join(s.backupDir, backupName(start))
+	if err := s.storage.Backup(path); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Scheduled backup failed")
+		return
+	}
+
+	removed, err := rotateBackups(s.backupDir, s.backupKeep)
+	if err != nil {
+		log.Error().Err(err).Str("dir", s.backupDir).Msg("Failed to rotate backups")
+	}
+
+	log.Info().
+		Str("path", path).
+		Int("rotated", removed).
+		Dur("duration", time.Since(start)).
+		Msg("Scheduled backup written")
+}
+
+// rotateBackups removes the oldest backups in dir so that at most keep remain.
+// Only files matching the backup name pattern are considered, keep 0 disables rotation.
+func rotateBackups(dir string, keep int) (int, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) <= keep {
+		return 0, nil
+	}
+
+	// Timestamped names sort oldest first
+	sort.Strings(names)
+
+	removed := 0
+	for _, name := range names[:len(names)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// backupName returns the file name of a backup taken at t.
+func backupName(t time.Time) string {
+	return backupPrefix + t.Format(backupTimeFormat) + backupSuffix
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/transfer"
+)
+
+// handleExport streams all nodes as a file download.
+// Query params: ?format=json (json, ndjson, csv)
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = transfer.FormatJSON
+	}
+	if !transfer.ValidFormat(format) {
+		http.Error(w, "Invalid format (json, ndjson, csv)", http.StatusBadRequest)
+		return
+	}
+
+	// Large databases take longer than the server write timeout to send
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	name := backupPrefix + time.Now().Format(backupTimeFormat) + "." + format
+	w.Header().Set("Content-Type", transfer.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+
+	// Headers are already sent when streaming fails, the download is left truncated
+	count, err := transfer.Export(w, format, s.storage)
+	if err != nil {
+		log.Warn().Err(err).Int64("exported", count).Msg("Failed to export nodes")
+		return
+	}
+
+	log.Info().Str("format", format).Int64("exported", count).Msg("Nodes exported")
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+const (
+	// maxNotesLength is the largest accepted length of node notes in characters.
+	maxNotesLength = 4096
+
+	// maxContactLength is the largest accepted length of a node owner contact in characters.
+	maxContactLength = 256
+
+	// maxTags is the largest number of tags accepted for a single node.
+	maxTags = 32
+
+	// maxTagLength is the largest accepted length of a single tag in characters.
+	maxTagLength = 32
+
+	// maxAnnotationBody is the largest accepted annotation request body in bytes.
+	maxAnnotationBody = 64 << 10
+)
+
+// handleGetAnnotation returns notes, owner contact and tags of a node.
+// Path: /api/nodes/{id}/notes
+func (s *Server) handleGetAnnotation(w http.ResponseWriter, r *http.Request) {
+	node := s.nodeFromRequest(w, r)
+	if node == nil {
+		return
+	}
+
+	annotation, err := s.storage.GetAnnotation(node.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("id", node.ID).Msg("Failed to fetch node annotation")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(annotation)
+}
+
+// handleSaveAnnotation replaces notes, owner contact and tags of a node.
+// Path: /api/nodes/{id}/notes
+// Body: {"notes": "...", "owner_contact": "...", "tags": ["partner", "test-server"]}
+func (s *Server) handleSaveAnnotation(w http.ResponseWriter, r *http.Request) {
+	node := s.nodeFromRequest(w, r)
+	if node == nil {
+		return
+	}
+
+	var req models.NodeAnnotation
+	r.Body = http.MaxBytesReader(w, r.Body, maxAnnotationBody)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	annotation, err := normalizeAnnotation(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	annotation.NodeID = node.ID
+	annotation.UpdatedAt = time.Now()
+
+	if err := s.storage.SaveAnnotation(annotation); err != nil {
+		log.Error().Err(err).Int64("id", node.ID).Msg("Failed to save node annotation")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Int64("id", node.ID).
+		Strs("tags", annotation.Tags).
+		Msg("Node annotation updated")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(annotation)
+}
+
+// handleDeleteAnnotation removes notes, owner contact and tags of a node.
+// Path: /api/nodes/{id}/notes
+func (s *Server) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	node := s.nodeFromRequest(w, r)
+	if node == nil {
+		return
+	}
+
+	if err := s.storage.DeleteAnnotation(node.ID); err != nil {
+		log.Error().Err(err).Int64("id", node.ID).Msg("Failed to delete node annotation")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Int64("id", node.ID).Msg("Node annotation deleted")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "message": "Annotation deleted"})
+}
+
+// handleTags returns all tags in use with the number of tagged nodes.
+func (s *Server) handleTags(w http.ResponseWriter, _ *http.Request) {
+	tags, err := s.storage.GetTags()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch tags")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	if tags == nil {
+		tags = []models.GroupCount{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tags)
+}
+
+// normalizeAnnotation validates an annotation and normalizes its tags:
+// lowercased, trimmed, deduplicated and sorted.
+// Tags may contain letters, digits and "-", "_", ".", ":".
+func normalizeAnnotation(a models.NodeAnnotation) (models.NodeAnnotation, error) {
+	a.Notes = strings.TrimSpace(a.Notes)
+	a.Contact = strings.TrimSpace(a.Contact)
+
+	if utf8.RuneCountInString(a.Notes) > maxNotesLength {
+		return a, fmt.Errorf("notes longer than %d characters", maxNotesLength)
+	}
+	if utf8.RuneCountInString(a.Contact) > maxContactLength {
+		return a, fmt.Errorf("owner contact longer than %d characters", maxContactLength)
+	}
+
+	seen := make(map[string]struct{}, len(a.Tags))
+	tags := make([]string, 0, len(a.Tags))
+	for _, tag := range a.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return a, fmt.Errorf("tag %q longer than %d characters", tag, maxTagLength)
+		}
+		for _, c := range tag {
+			if !validTagRune(c) {
+				return a, fmt.Errorf("tag %q contains invalid character %q", tag, c)
+			}
+		}
+
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+
+	if len(tags) > maxTags {
+		return a, errors.New("too many tags")
+	}
+	sort.Strings(tags)
+	a.Tags = tags
+
+	return a, nil
+}
+
+// validTagRune reports whether c is allowed in a tag.
+func validTagRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("-_.:", c)
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// maxBatchApplications is the largest number of applications accepted in a single batch report.
+// The batch body limit is the single report limit multiplied by it.
+const maxBatchApplications = 16
+
+// handleTelemetryBatch processes a batch report of a game server running several tracked mods.
+// Entries share the port, type and lifecycle event, so the server is queried (A2S) and located (GeoIP) once
+// and all its nodes are upserted in one transaction. Entries of applications outside
+// the whitelist, with invalid custom fields or failing the signature check are dropped,
+// and so are entries breaking the policy of their application, the rest is accepted.
+// The signature header may hold one signature per signed application.
+// Body: {"port": 2302, "type": "steam", "applications": [{"application": "MetricZ", "version": "1.1.0"}]}
+func (s *Server) handleTelemetryBatch(w http.ResponseWriter, r *http.Request) {
+	ip := GetRealIP(r, s.trustProxy)
+
+	if !s.validContentType(r) {
+		respondOK(w, "not accounted")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBody*maxBatchApplications)
+
+	var batch models.TelemetryBatchRequest
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &batch)
+	}
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("ip", ip).
+			Str("ua", r.UserAgent()).
+			Msg("Invalid JSON")
+
+		respondOK(w, "not accounted")
+		return
+	}
+
+	if batch.Port < 0 || batch.Port > 65535 {
+		log.Debug().
+			Str("ip", ip).
+			Int("port", batch.Port).
+			Msg("Invalid port")
+
+		respondOK(w, "not accounted")
+		return
+	}
+
+	if !validEvent(batch.Event) {
+		log.Debug().
+			Str("ip", ip).
+			Str("event", batch.Event).
+			Int("port", batch.Port).
+			Msg("Invalid event")
+
+		respondOK(w, "not accounted")
+		return
+	}
+
+	if len(batch.Applications) == 0 || len(batch.Applications) > maxBatchApplications {
+		log.Debug().
+			Str("ip", ip).
+			Int("port", batch.Port).
+			Int("applications", len(batch.Applications)).
+			Msg("Invalid batch size")
+
+		respondOK(w, "not accounted")
+		return
+	}
+
+	// Keep valid entries, the last one wins for a repeated application
+	sr := newSignedReport(r, body)
+	reqs := make([]models.TelemetryRequest, 0, len(batch.Applications))
+	trusts := make([]trust, 0, len(batch.Applications))
+	claims := make([]signatureClaim, 0, len(batch.Applications))
+	index := make(map[string]int, len(batch.Applications))
+	for _, req := range batch.Applications {
+		req.Port, req.Type, req.Event = batch.Port, batch.Type, batch.Event
+		rules := s.rulesFor(req.Application)
+		if req.Port == 0 {
+			req.Port = rules.defaultPort
+		}
+
+		if !s.allowedApp(req.Application) {
+			log.Debug().
+				Str("ip", ip).
+				Str("application", req.Application).
+				Str("version", req.Version).
+				Int("port", req.Port).
+				Msg("Invalid app")
+			continue
+		}
+
+		if !s.validUserAgent(r, ip, req.Application) || !rules.validReport(&req, ip) {
+			continue
+		}
+
+		if err := validateExtra(req.Extra); err != nil {
+			log.Debug().
+				Err(err).
+				Str("ip", ip).
+				Str("application", req.Application).
+				Str("version", req.Version).
+				Int("port", req.Port).
+				Msg("Invalid extra")
+			continue
+		}
+
+		if i, ok := index[req.Application]; ok {
+			reqs[i] = req
+			continue
+		}
+
+		verdict, claim, err := s.checkSignature(req.Application, sr)
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Str("ip", ip).
+				Str("application", req.Application).
+				Str("version", req.Version).
+				Int("port", req.Port).
+				Msg("Invalid signature")
+			continue
+		}
+
+		index[req.Application] = len(reqs)
+		reqs = append(reqs, req)
+		trusts = append(trusts, verdict)
+		claims = append(claims, claim)
+	}
+
+	if len(reqs) == 0 {
+		respondOK(w, "not accounted")
+		return
+	}
+
+	// An omitted port is the default port of the first entry for all of them,
+	// the shortest soft limit of the entries applies to the server
+	batch.Port = reqs[0].Port
+	softLimit := s.rulesFor(reqs[0].Application).softLimit
+	for i := range reqs {
+		reqs[i].Port = batch.Port
+		softLimit = min(softLimit, s.rulesFor(reqs[i].Application).softLimit)
+	}
+
+	if !boundaryEvent(batch.Event) && s.softLimited(ip, batch.Port, softLimit) {
+		log.Trace().
+			Str("ip", ip).
+			Int("port", batch.Port).
+			Int("applications", len(reqs)).
+			Msg("Dropped by soft limit hit")
+
+		respondOK(w, "ok")
+		return
+	}
+
+	// Signatures are recorded only for batches passing the soft limit
+	if !s.claimSignatures(claims) {
+		log.Debug().
+			Err(errSignatureReplay).
+			Str("ip", ip).
+			Int("port", batch.Port).
+			Int("applications", len(reqs)).
+			Msg("Invalid signature")
+
+		respondOK(w, "not accounted")
+		return
+	}
+
+	if s.enqueue(telemetryJob{Reqs: reqs, Trust: trusts, IP: ip}) {
+		log.Trace().
+			Str("ip", ip).
+			Int("port", batch.Port).
+			Int("applications", len(reqs)).
+			Msg("Success added")
+
+		respondOK(w, "successfully accounted")
+	} else {
+		log.Warn().
+			Str("ip", ip).
+			Int("port", batch.Port).
+			Int("applications", len(reqs)).
+			Msg("Queue full, telemetry dropped")
+
+		s.releaseSignatures(claims)
+		respondOK(w, "not accounted")
+	}
+}
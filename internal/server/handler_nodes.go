@@ -0,0 +1,219 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/semver"
+	"github.com/woozymasta/zenit/internal/storage"
+)
+
+const (
+	// defaultPageLimit is the page size of /api/nodes when no limit is given.
+	defaultPageLimit = 50
+
+	// maxPageLimit is the largest page size accepted by /api/nodes.
+	maxPageLimit = 1000
+
+	// defaultSummaryTop is the number of top servers returned by /api/summary when no top is given.
+	defaultSummaryTop = 20
+
+	// maxSummaryTop is the largest number of top servers accepted by /api/summary.
+	maxSummaryTop = 100
+
+	// maxActivityRange is the longest time range accepted by /api/activity.
+	maxActivityRange = 366 * 24 * time.Hour
+)
+
+// handleListNodes returns a single page of nodes matching the filter along with the total count.
+// Query params: see parseNodeFilter, plus ?limit=50&cursor=<next_cursor of the previous page>
+func (s *Server) handleListNodes(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseNodeFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter.Limit = defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+	filter.Cursor = r.URL.Query().Get("cursor")
+
+	page, err := s.storage.ListNodes(filter)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list nodes")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	if page.Nodes == nil {
+		page.Nodes = []models.Node{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// handleSummary returns aggregated statistics of nodes matching the filter:
+// totals, grouped counts, a last seen timeline and the top servers by report count.
+// Query params: see parseNodeFilter (sorting is ignored), plus ?top=20&bucket=day (hour, day)
+// and repeatable &extra_group=preset to count nodes per value of custom fields.
+func (s *Server) handleSummary(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseNodeFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	top := defaultSummaryTop
+	if v := r.URL.Query().Get("top"); v != "" {
+		top, err = strconv.Atoi(v)
+		if err != nil || top < 0 || top > maxSummaryTop {
+			http.Error(w, "Invalid top", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resolution := storage.ResolutionDay
+	switch v := r.URL.Query().Get("bucket"); v {
+	case "", storage.ResolutionDay:
+	case storage.ResolutionHour:
+		resolution = v
+	default:
+		http.Error(w, "Invalid bucket (hour, day)", http.StatusBadRequest)
+		return
+	}
+
+	extraGroups := r.URL.Query()["extra_group"]
+	if len(extraGroups) > maxExtraKeys {
+		http.Error(w, "Too many extra_group keys", http.StatusBadRequest)
+		return
+	}
+	for _, key := range extraGroups {
+		if !validExtraKey(key) {
+			http.Error(w, errInvalidExtraKey.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	summary, err := s.storage.GetSummary(filter, top, resolution, extraGroups)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to build summary")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(summary)
+}
+
+// parseNodeFilter reads node filtering and sorting query params, all optional:
+// ?app=MetricZ&country=DE&os=Linux&version=1.2.0&map=chernarusplus&tag=partner&q=search
+// &since=2025-12-01T00:00:00Z&verified=true&sort=count&order=desc
+// A version prefixed with <, <=, > or >= is a semantic version bound, e.g. &version=<1.1.0,
+// version may be repeated to give a range.
+// Custom fields are matched by &extra.<key>=<value>, e.g. &extra.preset=hardcore.
+func parseNodeFilter(r *http.Request) (storage.NodeFilter, error) {
+	q := r.URL.Query()
+
+	filter := storage.NodeFilter{
+		Application: q.Get("app"),
+		Country:     q.Get("country"),
+		OS:          q.Get("os"),
+		Map:         q.Get("map"),
+		Tag:         q.Get("tag"),
+		Search:      q.Get("q"),
+		Sort:        "last_seen",
+		Desc:        true,
+	}
+
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, errors.New("invalid 'since' time, expected RFC3339")
+		}
+		filter.Since = t
+	}
+
+	for _, v := range q["version"] {
+		op, ref := versionOperator(v)
+		if op == "" {
+			filter.Version, _ = semver.Normalize(ref)
+			continue
+		}
+
+		version, err := semver.Parse(ref)
+		if err != nil {
+			return filter, errors.New("invalid 'version' bound, expected a semantic version")
+		}
+		filter.VersionBounds = append(filter.VersionBounds, storage.VersionBound{Op: op, Version: version})
+	}
+
+	if v := q.Get("verified"); v != "" {
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("invalid 'verified', expected true or false")
+		}
+		filter.Verified = &verified
+	}
+
+	for param, values := range q {
+		key, ok := strings.CutPrefix(param, "extra.")
+		if !ok {
+			continue
+		}
+		if !validExtraKey(key) {
+			return filter, errInvalidExtraKey
+		}
+		if filter.Extra == nil {
+			filter.Extra = make(map[string]string)
+		}
+		filter.Extra[key] = values[0]
+	}
+
+	if v := q.Get("sort"); v != "" {
+		if !storage.ValidSort(v) {
+			return filter, errors.New("invalid sort key")
+		}
+		filter.Sort = v
+	}
+
+	switch q.Get("order") {
+	case "", "desc":
+		filter.Desc = true
+	case "asc":
+		filter.Desc = false
+	default:
+		return filter, errors.New("invalid order (asc, desc)")
+	}
+
+	return filter, nil
+}
+
+// versionOperator splits a version filter into its comparison operator and version,
+// the operator is empty for an exact match.
+func versionOperator(v string) (string, string) {
+	for _, op := range []string{"<=", ">=", "<", ">"} {
+		if ref, ok := strings.CutPrefix(v, op); ok && storage.ValidVersionOp(op) {
+			return op, strings.TrimSpace(ref)
+		}
+	}
+
+	return "", strings.TrimPrefix(v, "=")
+}
@@ -0,0 +1,14 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleQueueStats returns the live state of the telemetry queue: its depth and capacity,
+// running and busy workers, jobs processed and dropped since start and pending spool bytes.
+// This endpoint is protected by AdminAuthMiddleware.
+func (s *Server) handleQueueStats(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.queueStats())
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// handleNodeSessions returns sessions of a specific node started within a time range, most recent first.
+// Path: /api/nodes/{id}/sessions
+// Query params: ?from=2025-12-01T00:00:00Z&to=2025-12-31T00:00:00Z
+// from and to are optional RFC3339 timestamps; the default range is the last 30 days.
+func (s *Server) handleNodeSessions(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	node := s.nodeFromRequest(w, r)
+	if node == nil {
+		return
+	}
+
+	sessions, err := s.storage.GetNodeSessions(node.ID, from, to)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch node sessions")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sessions)
+}
+
+// handleVersionSessions returns session counts, uptime and crash rate per application version
+// for sessions started within a time range.
+// Query params: ?app=MetricZ&from=2025-12-01T00:00:00Z&to=2025-12-31T00:00:00Z
+// All params are optional; the default range is the last 30 days.
+func (s *Server) handleVersionSessions(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := s.storage.GetVersionSessions(r.URL.Query().Get("app"), from, to)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch version sessions")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
@@ -2,21 +2,42 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/cespare/xxhash/v2"
 	"github.com/rs/zerolog/log"
 	"github.com/woozymasta/zenit/internal/game"
 	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/storage"
 )
 
+// Limits of custom fields cap reports when --max-body-size is raised for larger extra objects,
+// under the default body size of 512 bytes the body limit is reached first.
+const (
+	// maxExtraKeys is the largest number of custom fields accepted in a single telemetry report.
+	maxExtraKeys = 32
+
+	// maxExtraKeyLength is the largest accepted length of a custom field key in bytes.
+	maxExtraKeyLength = 64
+
+	// maxExtraValueLength is the largest accepted length of a custom field string value in characters.
+	maxExtraValueLength = 256
+)
+
+// errInvalidExtraKey is returned for malformed custom field keys in query params.
+var errInvalidExtraKey = errors.New("invalid extra key")
+
 // handleTelemetry processes incoming telemetry reports.
 // It validates the application name, checks rate limits (soft), verifies the user agent,
 // and queues the request for asynchronous processing to avoid blocking the client.
+// A report may carry a lifecycle event (start, heartbeat, stop) tracking sessions of the node.
 func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
 	// Check headers
 	if r.Method != http.MethodPost {
@@ -32,38 +53,22 @@ func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
 	// Real IP
 	ip := GetRealIP(r, s.trustProxy)
 
-	// Content-Type Validation
-	ct := r.Header.Get("Content-Type")
-	if s.expectedCT != "" && !strings.HasPrefix(ct, s.expectedCT) {
-		log.Debug().
-			Str("content_type", ct).
-			Str("expected", s.expectedCT).
-			Msg("Invalid Content-Type")
-
+	// Content-Type validation
+	if !s.validContentType(r) {
 		respondOK(w, "not accounted")
 		return
 	}
 
-	// Check user agent - DayZ use blank UA
-	if !s.ignoreUA {
-		if r.UserAgent() != s.expectedUA {
-			log.Debug().
-				Str("ip", ip).
-				Str("ua", r.UserAgent()).
-				Str("method", r.Method).
-				Msg("Invalid UserAgent")
-
-			respondOK(w, "not accounted")
-			return
-		}
-	}
-
 	// Max body limit size
 	r.Body = http.MaxBytesReader(w, r.Body, s.maxBody)
 
-	// Decode body payload
+	// Decode body payload, the raw body is kept for the signature check
 	var req models.TelemetryRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
 		log.Debug().
 			Err(err).
 			Str("ip", ip).
@@ -73,6 +78,13 @@ func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
 		respondOK(w, "not accounted")
 		return
 	}
+	rules := s.rulesFor(req.Application)
+
+	// Check user agent - DayZ use blank UA
+	if !s.validUserAgent(r, ip, req.Application) {
+		respondOK(w, "not accounted")
+		return
+	}
 
 	// Port check
 	if req.Port < 0 || req.Port > 65535 {
@@ -87,47 +99,100 @@ func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if req.Port == 0 {
-		req.Port = 27016
+		req.Port = rules.defaultPort
+	}
+
+	// Lifecycle event check
+	if !validEvent(req.Event) {
+		log.Debug().
+			Str("ip", ip).
+			Str("application", req.Application).
+			Str("event", req.Event).
+			Int("port", req.Port).
+			Msg("Invalid event")
+
+		respondOK(w, "not accounted")
+		return
+	}
+
+	// Custom fields check, their total size is already bounded by the body limit
+	if err := validateExtra(req.Extra); err != nil {
+		log.Debug().
+			Err(err).
+			Str("ip", ip).
+			Str("application", req.Application).
+			Str("version", req.Version).
+			Int("port", req.Port).
+			Msg("Invalid extra")
+
+		respondOK(w, "not accounted")
+		return
 	}
 
 	// Chech application name whitelist
-	if len(s.allowedApps) > 0 {
-		hash := xxhash.Sum64String(req.Application)
-		if _, allowed := s.allowedApps[hash]; !allowed {
-			log.Debug().
-				Str("ip", ip).
-				Str("application", req.Application).
-				Str("version", req.Version).
-				Int("port", req.Port).
-				Msg("Invalid app")
+	if !s.allowedApp(req.Application) {
+		log.Debug().
+			Str("ip", ip).
+			Str("application", req.Application).
+			Str("version", req.Version).
+			Int("port", req.Port).
+			Msg("Invalid app")
 
-			respondOK(w, "not accounted")
-			return
-		}
+		respondOK(w, "not accounted")
+		return
 	}
 
-	// Soft Limit
-	softKey := fmt.Sprintf("%s:%d", ip, req.Port)
-	if val, ok := s.seenCache.Load(softKey); ok {
-		if lastSeen, ok := val.(time.Time); ok {
-			if time.Since(lastSeen) < s.softLimitDur {
-				log.Trace().
-					Str("ip", ip).
-					Str("application", req.Application).
-					Str("version", req.Version).
-					Int("port", req.Port).
-					Msg("Dropped by soft limit hit")
-
-				respondOK(w, "ok")
-				return
-			}
-		}
+	// Application policy check
+	if !rules.validReport(&req, ip) {
+		respondOK(w, "not accounted")
+		return
+	}
+
+	// Signature check
+	verdict, claim, err := s.checkSignature(req.Application, newSignedReport(r, body))
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("ip", ip).
+			Str("application", req.Application).
+			Str("version", req.Version).
+			Int("port", req.Port).
+			Msg("Invalid signature")
+
+		respondOK(w, "not accounted")
+		return
+	}
+
+	// Soft Limit, session starts and stops are never skipped
+	if !boundaryEvent(req.Event) && s.softLimited(ip, req.Port, rules.softLimit) {
+		log.Trace().
+			Str("ip", ip).
+			Str("application", req.Application).
+			Str("version", req.Version).
+			Int("port", req.Port).
+			Msg("Dropped by soft limit hit")
+
+		respondOK(w, "ok")
+		return
+	}
+
+	// Signatures are recorded only for reports passing the soft limit
+	claims := []signatureClaim{claim}
+	if !s.claimSignatures(claims) {
+		log.Debug().
+			Err(errSignatureReplay).
+			Str("ip", ip).
+			Str("application", req.Application).
+			Str("version", req.Version).
+			Int("port", req.Port).
+			Msg("Invalid signature")
+
+		respondOK(w, "not accounted")
+		return
 	}
-	s.seenCache.Store(softKey, time.Now())
 
 	// Send to queue
-	select {
-	case s.queue <- telemetryJob{Req: req, IP: ip}:
+	if s.enqueue(telemetryJob{Reqs: []models.TelemetryRequest{req}, Trust: []trust{verdict}, IP: ip}) {
 		log.Trace().
 			Str("ip", ip).
 			Str("application", req.Application).
@@ -136,7 +201,7 @@ func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
 			Msg("Success added")
 
 		respondOK(w, "successfully accounted")
-	default:
+	} else {
 		log.Warn().
 			Str("ip", ip).
 			Str("application", req.Application).
@@ -144,16 +209,86 @@ func (s *Server) handleTelemetry(w http.ResponseWriter, r *http.Request) {
 			Int("port", req.Port).
 			Msg("Queue full, telemetry dropped")
 
+		s.releaseSignatures(claims)
 		respondOK(w, "not accounted")
 	}
 }
 
+// validContentType checks the Content-Type of a telemetry request.
+func (s *Server) validContentType(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	if s.expectedCT != "" && !strings.HasPrefix(ct, s.expectedCT) {
+		log.Debug().
+			Str("content_type", ct).
+			Str("expected", s.expectedCT).
+			Msg("Invalid Content-Type")
+
+		return false
+	}
+
+	return true
+}
+
+// allowedApp reports whether the application passes the whitelist, any application does if it is empty.
+func (s *Server) allowedApp(app string) bool {
+	if len(s.allowedApps) == 0 {
+		return true
+	}
+
+	_, allowed := s.allowedApps[xxhash.Sum64String(app)]
+	return allowed
+}
+
+// softLimited reports whether the game server at ip:port was accepted within the soft limit duration,
+// otherwise it remembers the server as seen now.
+func (s *Server) softLimited(ip string, port int, softLimit time.Duration) bool {
+	softKey := fmt.Sprintf("%s:%d", ip, port)
+	if val, ok := s.seenCache.Load(softKey); ok {
+		if lastSeen, ok := val.(time.Time); ok && time.Since(lastSeen) < softLimit {
+			return true
+		}
+	}
+	s.seenCache.Store(softKey, time.Now())
+
+	return false
+}
+
 // worker is a background goroutine that processes jobs from the telemetry queue.
-func (s *Server) worker() {
+// With an idle timeout it stops when no job arrived for that long, otherwise when the queue is closed.
+func (s *Server) worker(idleTimeout time.Duration) {
 	defer s.wg.Done()
+	defer s.workers.Add(-1)
+
+	var (
+		timer *time.Timer
+		idle  <-chan time.Time
+	)
+	if idleTimeout > 0 {
+		timer = time.NewTimer(idleTimeout)
+		defer timer.Stop()
+		idle = timer.C
+	}
+
+	for {
+		select {
+		case job, ok := <-s.queue:
+			if !ok {
+				return
+			}
 
-	for job := range s.queue {
-		s.processJob(job)
+			s.busy.Add(1)
+			s.processJob(job)
+			s.busy.Add(-1)
+			s.processed.Add(1)
+
+			if timer != nil {
+				timer.Reset(idleTimeout)
+			}
+
+		case <-idle:
+			log.Debug().Int64("workers", s.workers.Load()-1).Msg("Idle telemetry worker stopped")
+			return
+		}
 	}
 }
 
@@ -164,10 +299,18 @@ func respondOK(w http.ResponseWriter, status string) {
 	_, _ = fmt.Fprint(w, status)
 }
 
-// processJob executes the logic for a single telemetry request.
-// It queries the game server (A2S), resolves the country (GeoIP), and upserts the data to the storage.
+// processJob executes the logic for the telemetry requests of a job.
+// It queries the game server (A2S) and resolves the country (GeoIP) once,
+// and hands the nodes of all requests to the writer to be upserted in one transaction.
 func (s *Server) processJob(job telemetryJob) {
-	nodeType := job.Req.Type
+	if len(job.Reqs) == 0 {
+		job.done()
+		return
+	}
+	port := job.Reqs[0].Port
+	event := job.Reqs[0].Event
+
+	nodeType := job.Reqs[0].Type
 	if nodeType == "" {
 		nodeType = "generic"
 	}
@@ -182,6 +325,7 @@ func (s *Server) processJob(job telemetryJob) {
 		players      byte
 		maxPlayers   byte
 		a2sSucceeded bool
+		a2sFailed    bool
 	)
 
 	queryIP := job.IP
@@ -189,17 +333,30 @@ func (s *Server) processJob(job telemetryJob) {
 		queryIP = "127.0.0.1"
 	}
 
-	if nodeType == "steam" || nodeType == "a2s" {
+	// Applications requiring A2S are queried whatever type they report
+	requireA2S := false
+	for _, req := range job.Reqs {
+		requireA2S = requireA2S || s.rulesFor(req.Application).requireA2S
+	}
+
+	// A stopping server does not answer, its A2S data is kept from earlier reports
+	if event == models.EventStop {
+		log.Trace().
+			Str("ip", queryIP).
+			Int("port", port).
+			Msg("Skipping A2S query for stop event")
+	} else if nodeType == "steam" || nodeType == "a2s" || requireA2S {
 		parsedIP := net.ParseIP(queryIP)
 		if parsedIP != nil && parsedIP.To4() != nil {
-			info, err := game.QueryServer(queryIP, job.Req.Port, s.a2sOptions)
+			info, err := game.QueryServer(queryIP, port, s.a2sOptions)
 			if err != nil {
 				log.Debug().
 					Err(err).
 					Str("ip", queryIP).
-					Int("port", job.Req.Port).
+					Int("port", port).
 					Msg("A2S query failed")
 				a2sSucceeded = false
+				a2sFailed = true
 			} else {
 				serverName = info.Name
 				mapName = info.Map
@@ -229,35 +386,116 @@ func (s *Server) processJob(job telemetryJob) {
 	}
 
 	// Model prepare
-	node := models.Node{
-		Application: job.Req.Application,
-		IP:          queryIP,
-		Port:        job.Req.Port,
-		Version:     job.Req.Version,
-		Type:        nodeType,
-		CountryCode: country,
-
-		// A2S data (can be emty)
-		ServerName:  serverName,
-		MapName:     mapName,
-		Players:     players,
-		MaxPlayers:  maxPlayers,
-		GameVersion: gameVer,
-		GameName:    gameName,
-		ServerOS:    serverOS,
-
-		FirstSeen: time.Now(),
-		LastSeen:  time.Now(),
-	}
-
-	// Write to DB
-	if err := s.storage.UpsertNode(node); err != nil {
-		log.Error().Err(err).Msg("Failed to save node to DB")
+	now := job.Received
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	// A server still loading may answer later, it is queried again by the retry scheduler
+	var retryAt time.Time
+	if a2sFailed && len(s.retryDelays) > 0 {
+		retryAt = time.Now().Add(s.retryDelays[0])
+	}
+	reports := make([]storage.NodeReport, 0, len(job.Reqs))
+	for i, req := range job.Reqs {
+		if !a2sSucceeded && event != models.EventStop && s.rulesFor(req.Application).requireA2S {
+			log.Debug().
+				Str("ip", queryIP).
+				Str("application", req.Application).
+				Int("port", port).
+				Msg("Dropped by policy, A2S query required")
+			continue
+		}
+
+		node := models.Node{
+			Application: req.Application,
+			IP:          queryIP,
+			Port:        port,
+			Version:     req.Version,
+			Type:        nodeType,
+			CountryCode: country,
+
+			// A2S data (can be emty)
+			ServerName:  serverName,
+			MapName:     mapName,
+			Players:     players,
+			MaxPlayers:  maxPlayers,
+			GameVersion: gameVer,
+			GameName:    gameName,
+			ServerOS:    serverOS,
+
+			Extra: req.Extra,
+
+			FirstSeen: now,
+			LastSeen:  now,
+		}
+		node.Verified = job.Trust[i] == trustVerified
+		reports = append(reports, storage.NodeReport{
+			Node:           node,
+			Online:         a2sSucceeded,
+			Event:          event,
+			UptimeInterval: s.rulesFor(req.Application).heartbeatInterval,
+			RetryAt:        retryAt,
+			KeepVerified:   job.Trust[i] == trustKeep,
+		})
+	}
+
+	if len(reports) == 0 {
+		job.done()
 		return
 	}
 
+	// Hand over to the batch writer, blocks while the writer is busy
+	s.writes <- writeJob{reports: reports, ack: job.ack}
+
 	log.Debug().
-		Str("ip", node.IP).
+		Str("ip", queryIP).
+		Int("port", port).
+		Int("applications", len(reports)).
 		Bool("a2s", a2sSucceeded).
-		Msg("Telemetry saved")
+		Msg("Telemetry processed")
+}
+
+// validateExtra checks custom fields of a telemetry report: at most maxExtraKeys keys
+// made of letters, digits and "_-.", with string, number or bool values.
+func validateExtra(extra map[string]interface{}) error {
+	if len(extra) > maxExtraKeys {
+		return fmt.Errorf("too many extra fields, at most %d allowed", maxExtraKeys)
+	}
+
+	for key, v := range extra {
+		if !validExtraKey(key) {
+			return fmt.Errorf("invalid extra key %q", key)
+		}
+
+		switch v := v.(type) {
+		case string:
+			if utf8.RuneCountInString(v) > maxExtraValueLength {
+				return fmt.Errorf("extra %q is longer than %d characters", key, maxExtraValueLength)
+			}
+		case float64, bool:
+		default:
+			return fmt.Errorf("extra %q must be a string, number or bool", key)
+		}
+	}
+
+	return nil
+}
+
+// validExtraKey reports whether key is a non-empty custom field key
+// of at most maxExtraKeyLength ASCII letters, digits and "_-.".
+func validExtraKey(key string) bool {
+	if key == "" || len(key) > maxExtraKeyLength {
+		return false
+	}
+
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-', c == '.':
+		default:
+			return false
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateExtra(t *testing.T) {
+	tooMany := make(map[string]interface{}, maxExtraKeys+1)
+	full := make(map[string]interface{}, maxExtraKeys)
+	for i := 0; i < maxExtraKeys; i++ {
+		key := fmt.Sprintf("key%02d", i)
+		tooMany[key] = true
+		full[key] = true
+	}
+	tooMany["one_more"] = true
+
+	tests := []struct {
+		name    string
+		extra   map[string]interface{}
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "empty", extra: map[string]interface{}{}},
+		{name: "valid", extra: map[string]interface{}{"preset": "pvp", "slots": 60.0, "modded": true}},
+		{name: "key characters", extra: map[string]interface{}{"Mod_v2-x.preset": "pvp"}},
+		{name: "longest key", extra: map[string]interface{}{strings.Repeat("k", maxExtraKeyLength): "v"}},
+		{name: "longest value", extra: map[string]interface{}{"motd": strings.Repeat("ж", maxExtraValueLength)}},
+		{name: "most keys", extra: full},
+		{name: "too many keys", extra: tooMany, wantErr: true},
+		{name: "empty key", extra: map[string]interface{}{"": "v"}, wantErr: true},
+		{name: "key too long", extra: map[string]interface{}{strings.Repeat("k", maxExtraKeyLength+1): "v"}, wantErr: true},
+		{name: "key with space", extra: map[string]interface{}{"game mode": "pvp"}, wantErr: true},
+		{name: "key with non-ASCII", extra: map[string]interface{}{"режим": "pvp"}, wantErr: true},
+		{name: "key with operator", extra: map[string]interface{}{"preset=": "pvp"}, wantErr: true},
+		{
+			name:    "value too long",
+			extra:   map[string]interface{}{"motd": strings.Repeat("ж", maxExtraValueLength+1)},
+			wantErr: true,
+		},
+		{name: "null value", extra: map[string]interface{}{"preset": nil}, wantErr: true},
+		{name: "object value", extra: map[string]interface{}{"preset": map[string]interface{}{}}, wantErr: true},
+		{name: "array value", extra: map[string]interface{}{"mods": []interface{}{"a"}}, wantErr: true},
+		{name: "integer value", extra: map[string]interface{}{"slots": 60}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateExtra(tt.extra); (err != nil) != tt.wantErr {
+				t.Errorf("validateExtra() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateExtraDecoded(t *testing.T) {
+	// Values as decoded from a report body, numbers arrive as float64
+	var req struct {
+		Extra map[string]interface{} `json:"extra"`
+	}
+	if err := json.Unmarshal([]byte(`{"extra":{"preset":"pvp","slots":60,"modded":true}}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateExtra(req.Extra); err != nil {
+		t.Errorf("validateExtra() error = %v", err)
+	}
+
+	req.Extra = nil
+	if err := json.Unmarshal([]byte(`{"extra":{"mods":["a","b"]}}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateExtra(req.Extra); err == nil {
+		t.Error("validateExtra() accepted an array")
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// handleNodeUptime returns availability of a specific node derived from the gaps between its reports,
+// tracked for applications with an expected heartbeat interval.
+// Path: /api/nodes/{id}/uptime
+// Query params: ?from=2025-12-01T00:00:00Z&to=2025-12-31T00:00:00Z
+// from and to are optional RFC3339 timestamps; the default range is the last 30 days.
+func (s *Server) handleNodeUptime(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	node := s.nodeFromRequest(w, r)
+	if node == nil {
+		return
+	}
+
+	uptime, err := s.storage.GetNodeUptime(node.ID, from, to)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch node uptime")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(uptime)
+}
+
+// handleVersionUptime returns availability of tracked nodes per application and the version they run.
+// Query params: ?app=MetricZ&from=2025-12-01T00:00:00Z&to=2025-12-31T00:00:00Z
+// All params are optional; the default range is the last 30 days.
+func (s *Server) handleVersionUptime(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	uptime, err := s.storage.GetVersionUptime(r.URL.Query().Get("app"), from, to)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch version uptime")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(uptime)
+}
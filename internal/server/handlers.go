@@ -2,14 +2,17 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"text/template"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/woozymasta/zenit/assets"
 	"github.com/woozymasta/zenit/internal/game"
 	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/storage"
 )
 
 // handleIndex serves the main landing page (landing.min.html).
@@ -30,15 +33,23 @@ func (s *Server) handleDashboard(w http.ResponseWriter, _ *http.Request) {
 }
 
 // handleStats returns a JSON list of all collected server nodes.
+// Optional filter and sort query params are the same as for /api/nodes (see parseNodeFilter).
 // This endpoint is protected by AdminAuthMiddleware.
-func (s *Server) handleStats(w http.ResponseWriter, _ *http.Request) {
-	nodes, err := s.storage.GetNodes()
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseNodeFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.storage.ListNodes(filter)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch nodes")
 		http.Error(w, "Database Error", http.StatusInternalServerError)
 		return
 	}
 
+	nodes := page.Nodes
 	if nodes == nil {
 		nodes = []models.Node{}
 	}
@@ -79,79 +90,284 @@ func (s *Server) handleServerQuery(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleGetNode returns details for a specific node.
-// Query params: ?app=MetricZ&ip=1.2.3.4&port=2302
+// Path: /api/nodes/{id}, legacy query params: /api/node?app=MetricZ&ip=1.2.3.4&port=2302
 func (s *Server) handleGetNode(w http.ResponseWriter, r *http.Request) {
-	app := r.URL.Query().Get("app")
-	ip := r.URL.Query().Get("ip")
-	portStr := r.URL.Query().Get("port")
+	node := s.nodeFromRequest(w, r)
+	if node == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(node)
+}
+
+// handleDeleteNode soft-deletes a specific node, it stays in trash until restored or purged.
+// Path: /api/nodes/{id}, legacy query params: /api/node?app=MetricZ&ip=1.2.3.4&port=2302
+func (s *Server) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-	if app == "" || ip == "" || portStr == "" {
-		http.Error(w, "Missing required params (app, ip, port)", http.StatusBadRequest)
+	node := s.nodeFromRequest(w, r)
+	if node == nil {
 		return
 	}
 
-	port, err := strconv.Atoi(portStr)
+	if err := s.storage.DeleteNodeByID(node.ID); err != nil {
+		log.Error().Err(err).
+			Int64("id", node.ID).
+			Str("app", node.Application).
+			Str("ip", node.IP).
+			Int("port", node.Port).
+			Msg("Failed to delete node")
+
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().
+		Int64("id", node.ID).
+		Str("app", node.Application).
+		Str("ip", node.IP).
+		Int("port", node.Port).
+		Msg("Node deleted manually")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "message": "Node moved to trash"})
+}
+
+// handleDeletedNodes returns soft-deleted nodes, most recently deleted first.
+func (s *Server) handleDeletedNodes(w http.ResponseWriter, _ *http.Request) {
+	nodes, err := s.storage.GetDeletedNodes()
 	if err != nil {
-		http.Error(w, "Invalid port", http.StatusBadRequest)
+		log.Error().Err(err).Msg("Failed to fetch deleted nodes")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	if nodes == nil {
+		nodes = []models.Node{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(nodes)
+}
+
+// handleRestoreNode moves a soft-deleted node back out of trash.
+// Path: /api/nodes/{id}/restore
+func (s *Server) handleRestoreNode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		http.Error(w, "Invalid node id", http.StatusBadRequest)
 		return
 	}
 
-	node, err := s.storage.GetNode(app, ip, port)
+	restored, err := s.storage.RestoreNode(id)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to fetch node")
+		log.Error().Err(err).Int64("id", id).Msg("Failed to restore node")
 		http.Error(w, "Database Error", http.StatusInternalServerError)
 		return
 	}
 
-	if node == nil {
+	if !restored {
 		http.NotFound(w, r)
 		return
 	}
 
+	log.Info().Int64("id", id).Msg("Node restored manually")
+
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(node)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "message": "Node restored"})
 }
 
-// handleDeleteNode removes a specific node from the database.
-// Query params: ?app=MetricZ&ip=1.2.3.4&port=2302
-func (s *Server) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleNodeHistory returns the recorded snapshots of a specific node within a time range.
+// Path: /api/nodes/{id}/history, legacy query params: /api/node/history?app=MetricZ&ip=1.2.3.4&port=2302
+// Query params: ?from=2025-12-01T00:00:00Z&to=2025-12-08T00:00:00Z&resolution=hour
+// from and to are optional RFC3339 timestamps; the default range is the last 7 days.
+// resolution is optional: "raw" (default) returns snapshots, "hour" or "day" return aggregates.
+func (s *Server) handleNodeHistory(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r, 7*24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	app := r.URL.Query().Get("app")
-	ip := r.URL.Query().Get("ip")
-	portStr := r.URL.Query().Get("port")
+	resolution := r.URL.Query().Get("resolution")
+	switch resolution {
+	case "", "raw", storage.ResolutionHour, storage.ResolutionDay:
+	default:
+		http.Error(w, "Invalid resolution (raw, hour, day)", http.StatusBadRequest)
+		return
+	}
 
-	if app == "" || ip == "" || portStr == "" {
-		http.Error(w, "Missing required params (app, ip, port)", http.StatusBadRequest)
+	node := s.nodeFromRequest(w, r)
+	if node == nil {
 		return
 	}
 
-	port, err := strconv.Atoi(portStr)
+	if resolution == storage.ResolutionHour || resolution == storage.ResolutionDay {
+		stats, err := s.storage.GetNodeStats(node.Application, node.IP, node.Port, resolution, from, to)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch node stats")
+			http.Error(w, "Database Error", http.StatusInternalServerError)
+			return
+		}
+
+		if stats == nil {
+			stats = []models.NodeStat{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(stats)
+		return
+	}
+
+	history, err := s.storage.GetNodeHistory(node.Application, node.IP, node.Port, from, to)
 	if err != nil {
-		http.Error(w, "Invalid port", http.StatusBadRequest)
+		log.Error().Err(err).Msg("Failed to fetch node history")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
 		return
 	}
 
-	if err := s.storage.DeleteNode(app, ip, port); err != nil {
-		log.Error().Err(err).
-			Str("app", app).
-			Str("ip", ip).
-			Int("port", port).
-			Msg("Failed to delete node")
+	if history == nil {
+		history = []models.NodeSnapshot{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(history)
+}
+
+// nodeFromRequest resolves the node addressed by the {id} path value,
+// or by the legacy ?app=MetricZ&ip=1.2.3.4&port=2302 query params on routes without it.
+// On failure it writes the error response and returns nil.
+func (s *Server) nodeFromRequest(w http.ResponseWriter, r *http.Request) *models.Node {
+	var (
+		node *models.Node
+		err  error
+	)
+
+	if idStr := r.PathValue("id"); idStr != "" {
+		id, perr := strconv.ParseInt(idStr, 10, 64)
+		if perr != nil || id < 1 {
+			http.Error(w, "Invalid node id", http.StatusBadRequest)
+			return nil
+		}
+
+		node, err = s.storage.GetNodeByID(id)
+	} else {
+		app := r.URL.Query().Get("app")
+		ip := r.URL.Query().Get("ip")
+		portStr := r.URL.Query().Get("port")
+
+		if app == "" || ip == "" || portStr == "" {
+			http.Error(w, "Missing required params (app, ip, port)", http.StatusBadRequest)
+			return nil
+		}
 
+		port, perr := strconv.Atoi(portStr)
+		if perr != nil {
+			http.Error(w, "Invalid port", http.StatusBadRequest)
+			return nil
+		}
+
+		node, err = s.storage.GetNode(app, ip, port)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch node")
 		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return nil
+	}
+
+	if node == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	return node
+}
+
+// handleVersionEvents returns version changes (upgrades, downgrades and first reports) of nodes.
+// Query params: ?app=MetricZ&from=2025-12-01T00:00:00Z&to=2025-12-08T00:00:00Z
+// All params are optional; the default range is all time.
+func (s *Server) handleVersionEvents(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Info().
-		Str("app", app).
-		Str("ip", ip).
-		Int("port", port).
-		Msg("Node deleted manually")
+	events, err := s.storage.GetVersionEvents(r.URL.Query().Get("app"), from, to)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch version events")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	if events == nil {
+		events = []models.VersionEvent{}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "message": "Node deleted"})
+	_ = json.NewEncoder(w).Encode(events)
+}
+
+// handleActivity returns daily, weekly and monthly active nodes (DAU, WAU, MAU) per application
+// for every UTC day in a time range, with the latest values and stickiness (DAU/MAU).
+// Query params: ?app=MetricZ&from=2025-12-01T00:00:00Z&to=2025-12-31T00:00:00Z
+// All params are optional; the default range is the last 30 days, the longest is a year.
+func (s *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if to.Sub(from) > maxActivityRange {
+		http.Error(w, "Time range longer than a year", http.StatusBadRequest)
+		return
+	}
+
+	activity, err := s.storage.GetActivity(r.URL.Query().Get("app"), from, to)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch activity")
+		http.Error(w, "Database Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(activity)
+}
+
+// parseTimeRange reads optional RFC3339 "from" and "to" query params.
+// Missing "to" defaults to now, missing "from" defaults to "to" minus def,
+// or to the beginning of time if def is zero.
+func parseTimeRange(r *http.Request, def time.Duration) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid 'to' time, expected RFC3339")
+		}
+		to = t.UTC()
+	}
+
+	var from time.Time
+	if def > 0 {
+		from = to.Add(-def)
+	}
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid 'from' time, expected RFC3339")
+		}
+		from = t.UTC()
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("'from' must be before 'to'")
+	}
+
+	return from, to, nil
 }
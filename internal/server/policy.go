@@ -0,0 +1,160 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/config"
+	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/semver"
+)
+
+// defaultPort is used for reports without a port when the application policy sets none.
+const defaultPort = 27016
+
+// appRules are the ingestion rules in effect for an application,
+// its policy from the policy file merged over the global flags.
+type appRules struct {
+	// version is the pattern reported versions must match, nil allows any.
+	version *regexp.Regexp
+
+	// expectedUA is the expected User-Agent.
+	expectedUA string
+
+	// types lists allowed report types, empty allows any.
+	types []string
+
+	// softLimit is the duration reports of a recently seen server are skipped for.
+	softLimit time.Duration
+
+	// heartbeatInterval is the expected interval between reports of a node, zero disables uptime tracking.
+	heartbeatInterval time.Duration
+
+	// defaultPort is used for reports without a port.
+	defaultPort int
+
+	// ignoreUA disables User-Agent validation.
+	ignoreUA bool
+
+	// requireA2S queries every report and drops those whose query failed.
+	requireA2S bool
+
+	// rejectInvalidVersion drops reports whose version is not a semantic version.
+	rejectInvalidVersion bool
+}
+
+// newRules merges application policies over the global rules, keyed by application name.
+func newRules(global appRules, policies map[string]*config.AppPolicy) map[string]appRules {
+	rules := make(map[string]appRules, len(policies))
+	for app, p := range policies {
+		r := global
+		if p.UserAgent != nil {
+			r.expectedUA = *p.UserAgent
+		}
+		if p.IgnoreUserAgent != nil {
+			r.ignoreUA = *p.IgnoreUserAgent
+		}
+		if p.RejectInvalidVersion != nil {
+			r.rejectInvalidVersion = *p.RejectInvalidVersion
+		}
+		if p.DefaultPort != 0 {
+			r.defaultPort = p.DefaultPort
+		}
+		if p.SoftLimit != 0 {
+			r.softLimit = p.SoftLimit
+		}
+		if p.HeartbeatInterval != 0 {
+			r.heartbeatInterval = p.HeartbeatInterval
+		}
+		r.version = p.VersionPattern
+		r.types = p.Types
+		r.requireA2S = p.RequireA2S
+
+		rules[app] = r
+	}
+
+	return rules
+}
+
+// rulesFor returns the ingestion rules of an application, the global rules if it has no policy.
+func (s *Server) rulesFor(app string) appRules {
+	if r, ok := s.rules[app]; ok {
+		return r
+	}
+
+	return s.globalRules
+}
+
+// validUserAgent checks the User-Agent of a report against the rules of its application.
+func (s *Server) validUserAgent(r *http.Request, ip, app string) bool {
+	rules := s.rulesFor(app)
+	if rules.ignoreUA || r.UserAgent() == rules.expectedUA {
+		return true
+	}
+
+	log.Debug().
+		Str("ip", ip).
+		Str("application", app).
+		Str("ua", r.UserAgent()).
+		Str("method", r.Method).
+		Msg("Invalid UserAgent")
+
+	return false
+}
+
+// allowedType reports whether the report type, "generic" if omitted, is allowed for the application.
+func (r appRules) allowedType(reportType string) bool {
+	if reportType == "" {
+		reportType = "generic"
+	}
+
+	return len(r.types) == 0 || slices.Contains(r.types, reportType)
+}
+
+// allowedVersion reports whether the reported version matches the application version pattern.
+func (r appRules) allowedVersion(version string) bool {
+	return r.version == nil || r.version.MatchString(version)
+}
+
+// validReport checks the type and version of a report against the rules of its application.
+// A semantic version is normalized in place, so "v1.2" is stored and matched as "1.2.0".
+func (r appRules) validReport(req *models.TelemetryRequest, ip string) bool {
+	if !r.allowedType(req.Type) {
+		log.Debug().
+			Str("ip", ip).
+			Str("application", req.Application).
+			Str("type", req.Type).
+			Int("port", req.Port).
+			Msg("Type not allowed by policy")
+
+		return false
+	}
+
+	var valid bool
+	if req.Version, valid = semver.Normalize(req.Version); !valid && req.Version != "" && r.rejectInvalidVersion {
+		log.Debug().
+			Str("ip", ip).
+			Str("application", req.Application).
+			Str("version", req.Version).
+			Int("port", req.Port).
+			Msg("Invalid semantic version")
+
+		return false
+	}
+
+	if !r.allowedVersion(req.Version) {
+		log.Debug().
+			Str("ip", ip).
+			Str("application", req.Application).
+			Str("version", req.Version).
+			Int("port", req.Port).
+			Msg("Version not allowed by policy")
+
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/game"
+	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/storage"
+)
+
+// pollConcurrency is the max number of background A2S queries waiting for an answer at once.
+const pollConcurrency = 20
+
+// runPolling queries known servers in the background at pollRate per minute, the stalest first,
+// so their players, map and online state stay current between reports.
+// Servers queried within pollInterval, by a report or a poll, are skipped.
+func (s *Server) runPolling() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Minute / time.Duration(s.pollRate))
+	defer ticker.Stop()
+
+	var (
+		wg       sync.WaitGroup
+		inFlight atomic.Int64
+		pending  []storage.ServerAddr
+	)
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+		}
+
+		// Polls in flight are not stored yet, the next batch is fetched after they finish
+		if len(pending) == 0 {
+			if inFlight.Load() > 0 {
+				continue
+			}
+
+			servers, err := s.storage.GetStaleServers(time.Now().Add(-s.pollInterval), s.pollRate)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to fetch servers to poll")
+				continue
+			}
+			if len(servers) == 0 {
+				continue
+			}
+			pending = servers
+		}
+
+		// A slow answer delays the next query rather than piling up queries
+		if inFlight.Load() >= pollConcurrency {
+			continue
+		}
+
+		server := pending[0]
+		pending = pending[1:]
+
+		inFlight.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer inFlight.Add(-1)
+			s.pollServer(server)
+		}()
+	}
+}
+
+// pollServer queries a known server and stores the answer, or marks its nodes offline if it does not answer.
+func (s *Server) pollServer(server storage.ServerAddr) {
+	node := models.Node{IP: server.IP, Port: server.Port}
+
+	info, err := game.QueryServer(server.IP, server.Port, s.a2sOptions)
+	online := err == nil
+	if online {
+		node.ServerName = info.Name
+		node.MapName = info.Map
+		node.Players = info.Players
+		node.MaxPlayers = info.MaxPlayers
+		node.GameVersion = info.Version
+		node.GameName = info.Game
+		node.ServerOS = info.Environment.String()
+	}
+	node.LastSeen = time.Now()
+
+	if err := s.storage.SaveServerPoll(node, online); err != nil {
+		log.Error().
+			Err(err).
+			Str("ip", server.IP).
+			Int("port", server.Port).
+			Msg("Failed to save server poll")
+		return
+	}
+
+	log.Trace().
+		Err(err).
+		Str("ip", server.IP).
+		Int("port", server.Port).
+		Bool("online", online).
+		Msg("Server polled")
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/spool"
+)
+
+const (
+	// scaleInterval is how often the adaptive worker pool checks the queue depth.
+	scaleInterval = time.Second
+
+	// scaleChecks is the number of consecutive checks the queue must stay filled before workers are added.
+	scaleChecks = 3
+
+	// workerIdleTimeout is how long a worker added by the adaptive pool waits for a job before it stops.
+	workerIdleTimeout = time.Minute
+)
+
+// startWorker starts a telemetry worker, see worker for idleTimeout.
+func (s *Server) startWorker(idleTimeout time.Duration) {
+	s.workers.Add(1)
+	s.wg.Add(1)
+	go s.worker(idleTimeout)
+}
+
+// runScaler grows the worker pool up to maxWorkers while the queue stays at least a quarter full,
+// which happens when workers wait for slow A2S queries. Added workers stop again once idle.
+func (s *Server) runScaler() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(scaleInterval)
+	defer ticker.Stop()
+
+	filled := 0
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			if len(s.queue) < max(cap(s.queue)/4, 1) {
+				filled = 0
+				continue
+			}
+
+			filled++
+			workers := int(s.workers.Load())
+			if filled < scaleChecks || workers >= s.maxWorkers {
+				continue
+			}
+			filled = 0
+
+			// Grow by half of the pool, so the pool reaches its limit in a few steps
+			add := min(max(workers/2, 1), s.maxWorkers-workers)
+			for i := 0; i < add; i++ {
+				s.startWorker(workerIdleTimeout)
+			}
+
+			log.Debug().
+				Int("depth", len(s.queue)).
+				Int("workers", workers+add).
+				Msg("Telemetry workers added")
+		}
+	}
+}
+
+// queueStats returns the live state of the telemetry queue and its workers.
+func (s *Server) queueStats() models.QueueStats {
+	stats := models.QueueStats{
+		StartedAt:   s.startedAt,
+		Depth:       len(s.queue),
+		Capacity:    cap(s.queue),
+		Workers:     s.workers.Load(),
+		MinWorkers:  s.minWorkers,
+		MaxWorkers:  max(s.maxWorkers, s.minWorkers),
+		BusyWorkers: s.busy.Load(),
+		Processed:   s.processed.Load(),
+		Dropped:     s.dropped.Load(),
+		Spool:       s.spool != nil,
+	}
+	if s.spool != nil {
+		stats.SpoolPending = s.spool.Pending()
+	}
+
+	return stats
+}
+
+// enqueue hands an accepted job over to the workers, through the spool if it is enabled.
+// It returns false if the job was dropped because the queue or the spool is full.
+func (s *Server) enqueue(job telemetryJob) bool {
+	job.Received = time.Now()
+	if s.push(job) {
+		return true
+	}
+
+	s.dropped.Add(1)
+	return false
+}
+
+// push adds a job to the spool if it is enabled, to the queue otherwise, without waiting.
+func (s *Server) push(job telemetryJob) bool {
+	if s.spool == nil {
+		select {
+		case s.queue <- job:
+			return true
+		default:
+			return false
+		}
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		log.Error().Err(err).Str("ip", job.IP).Msg("Failed to encode telemetry job")
+		return false
+	}
+
+	if err := s.spool.Push(data); err != nil {
+		if !errors.Is(err, spool.ErrFull) {
+			log.Error().Err(err).Str("ip", job.IP).Msg("Failed to spool telemetry job")
+		}
+		return false
+	}
+
+	return true
+}
+
+// runFeeder passes spooled jobs to the workers in order, waiting while the queue is full.
+// Jobs left in the spool at shutdown are processed after the next start.
+func (s *Server) runFeeder() {
+	defer close(s.feederDone)
+
+	for {
+		rec, ok, err := s.spool.Pop(s.shutdown)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to read telemetry spool")
+
+			select {
+			case <-s.shutdown:
+				return
+			case <-time.After(time.Second):
+				continue
+			}
+		}
+		if !ok {
+			return
+		}
+
+		var job telemetryJob
+		if err := json.Unmarshal(rec.Data, &job); err != nil {
+			log.Error().Err(err).Msg("Dropped undecodable spooled telemetry job")
+			s.spool.Ack(rec.Seq)
+			continue
+		}
+		seq := rec.Seq
+		job.ack = func() { s.spool.Ack(seq) }
+
+		select {
+		case s.queue <- job:
+		case <-s.shutdown:
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/game"
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+const (
+	// retryCheckInterval is how often due A2S query retries are looked up.
+	retryCheckInterval = 10 * time.Second
+
+	// retryBatch is the max number of A2S query retries run in one check.
+	retryBatch = 100
+
+	// retryConcurrency is the max number of A2S query retries run at once.
+	retryConcurrency = 10
+)
+
+// runA2SRetries periodically queries servers whose A2S query failed when they reported.
+// Retries are stored in the database, so they are resumed after a restart.
+func (s *Server) runA2SRetries() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(retryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			s.retryDueQueries()
+		}
+	}
+}
+
+// retryDueQueries runs due A2S query retries, a few at once, until all are done or the server shuts down.
+func (s *Server) retryDueQueries() {
+	retries, err := s.storage.GetDueA2SRetries(time.Now(), retryBatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch due A2S retries")
+		return
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, retryConcurrency)
+	)
+	defer wg.Wait()
+
+	for _, retry := range retries {
+		select {
+		case <-s.shutdown:
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.retryQuery(retry)
+		}()
+	}
+}
+
+// retryQuery queries a server again. On success its nodes are updated and the retry removed by the writer,
+// on failure the next retry is scheduled with the next delay, or the retry is removed once delays run out.
+func (s *Server) retryQuery(retry models.A2SRetry) {
+	logCtx := log.With().
+		Str("ip", retry.IP).
+		Int("port", retry.Port).
+		Int("attempt", retry.Attempt+1).
+		Logger()
+
+	info, err := game.QueryServer(retry.IP, retry.Port, s.a2sOptions)
+	if err != nil {
+		next := retry.Attempt + 1
+		if next >= len(s.retryDelays) {
+			logCtx.Debug().Err(err).Msg("A2S query retry failed, giving up")
+			if err := s.storage.DeleteA2SRetry(retry.IP, retry.Port); err != nil {
+				logCtx.Error().Err(err).Msg("Failed to remove A2S retry")
+			}
+			return
+		}
+
+		logCtx.Debug().Err(err).Dur("delay", s.retryDelays[next]).Msg("A2S query retry failed, rescheduled")
+		if err := s.storage.RescheduleA2SRetry(retry.IP, retry.Port, next, time.Now().Add(s.retryDelays[next])); err != nil {
+			logCtx.Error().Err(err).Msg("Failed to reschedule A2S retry")
+		}
+		return
+	}
+
+	// Only the A2S data is refreshed, report counts and last seen times are kept.
+	// The writer stores it and removes the retry.
+	s.writes <- writeJob{poll: &models.Node{
+		IP:          retry.IP,
+		Port:        retry.Port,
+		ServerName:  info.Name,
+		MapName:     info.Map,
+		Players:     info.Players,
+		MaxPlayers:  info.MaxPlayers,
+		GameVersion: info.Version,
+		GameName:    info.Game,
+		ServerOS:    info.Environment.String(),
+		LastSeen:    time.Now(),
+	}}
+
+	logCtx.Debug().Msg("A2S query retry succeeded")
+}
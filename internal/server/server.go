@@ -6,69 +6,233 @@ import (
 	"time"
 
 	"github.com/cespare/xxhash/v2"
+	"github.com/rs/zerolog/log"
 	"github.com/woozymasta/zenit/assets"
 	"github.com/woozymasta/zenit/internal/config"
 	"github.com/woozymasta/zenit/internal/geoip"
+	"github.com/woozymasta/zenit/internal/spool"
 	"github.com/woozymasta/zenit/internal/storage"
 )
 
 // New creates a new Server instance with the provided storage, GeoIP provider, and configuration.
-func New(store *storage.Repository, geo *geoip.Provider, cfg *config.Config) *Server {
+// The telemetry spool is optional and can be nil, the server closes it in StopWorkers.
+func New(store storage.Store, geo *geoip.Provider, queueSpool *spool.Spool, cfg *config.Config) *Server {
 	appMap := make(map[uint64]struct{})
 	for _, app := range cfg.Server.AllowedApps {
 		hash := xxhash.Sum64String(app)
 		appMap[hash] = struct{}{}
 	}
 
+	// Applications with a policy are allowed unless any application is
+	if len(appMap) > 0 {
+		for app := range cfg.Server.Policies {
+			appMap[xxhash.Sum64String(app)] = struct{}{}
+		}
+	}
+
+	globalRules := appRules{
+		expectedUA:  cfg.Server.ExpectedUA,
+		ignoreUA:    cfg.Server.IgnoreUA,
+		softLimit:   cfg.RateLimit.SoftLimitDur,
+		defaultPort: defaultPort,
+
+		heartbeatInterval:    cfg.Server.HeartbeatInterval,
+		rejectInvalidVersion: cfg.Server.RejectInvalidVersion,
+	}
+	rules := newRules(globalRules, cfg.Server.Policies)
+	softLimitDur := globalRules.softLimit
+	for _, r := range rules {
+		softLimitDur = max(softLimitDur, r.softLimit)
+	}
+
+	// Pairs are validated by config.Parse
+	secretMap, _ := cfg.Signing.SecretMap()
+	secrets := make(map[string][]byte, len(secretMap))
+	for app, secret := range secretMap {
+		secrets[app] = []byte(secret)
+	}
+	unsigned, _ := cfg.Signing.UnsignedMap()
+
+	// Any delay not above zero disables retries
+	retryDelays := cfg.A2S.Retry
+	for _, d := range retryDelays {
+		if d <= 0 {
+			retryDelays = nil
+			break
+		}
+	}
+
+	minWorkers := max(cfg.Queue.Workers, 1)
+	batchSize := max(cfg.Storage.WriteBatchSize, 1)
+	writeInterval := cfg.Storage.WriteInterval
+	if writeInterval <= 0 {
+		writeInterval = time.Second
+	}
+
 	return &Server{
 		storage:        store,
 		geoip:          geo,
 		a2sOptions:     cfg.A2S,
+		retryDelays:    retryDelays,
+		pollRate:       cfg.A2S.PollRate,
+		pollInterval:   cfg.A2S.PollInterval,
 		authToken:      cfg.Server.AuthToken,
 		allowedApps:    appMap,
 		maxBody:        cfg.Server.MaxBodySize,
 		trustProxy:     cfg.Server.TrustProxy,
 		hardLimitCount: cfg.RateLimit.HardLimitCount,
 		hardLimitWin:   cfg.RateLimit.HardLimitWin,
-		softLimitDur:   cfg.RateLimit.SoftLimitDur,
-		expectedUA:     cfg.Server.ExpectedUA,
-		ignoreUA:       cfg.Server.IgnoreUA,
+		softLimitDur:   softLimitDur,
 		expectedCT:     cfg.Server.ContentType,
+		globalRules:    globalRules,
+		rules:          rules,
+
+		secrets:         secrets,
+		unsigned:        unsigned,
+		unsignedDefault: cfg.Signing.Default,
+		signWindow:      cfg.Signing.Window,
 
-		queue:    make(chan telemetryJob, 1000),
-		shutdown: make(chan struct{}),
+		writeBatchSize:    batchSize,
+		writeInterval:     writeInterval,
+		backupInterval:    cfg.Storage.BackupInterval,
+		backupDir:         cfg.Storage.BackupDir,
+		backupKeep:        cfg.Storage.BackupKeep,
+		retentionInterval: cfg.Storage.RetentionInterval,
+		sessionTimeout:    cfg.Server.SessionTimeout,
+		retention: storage.RetentionPolicy{
+			Raw:      cfg.Storage.RetentionRaw,
+			Hourly:   cfg.Storage.RetentionHourly,
+			Daily:    cfg.Storage.RetentionDaily,
+			Activity: cfg.Storage.RetentionActivity,
+			Deleted:  cfg.Storage.RetentionDeleted,
+		},
+
+		startedAt:  time.Now(),
+		minWorkers: minWorkers,
+		maxWorkers: cfg.Queue.MaxWorkers,
+		spool:      queueSpool,
+		queue:      make(chan telemetryJob, max(cfg.Queue.Size, 1)),
+		writes:     make(chan writeJob, batchSize),
+		writerDone: make(chan struct{}),
+		feederDone: make(chan struct{}),
+		shutdown:   make(chan struct{}),
 	}
 }
 
-// StartWorkers initializes the background worker pool for processing telemetry jobs
-// and the cache cleanup routine.
+// StartWorkers initializes the background worker pool for processing telemetry jobs,
+// the batch writer and the cache cleanup routine.
 func (s *Server) StartWorkers() {
-	workers := 10
-	for i := 0; i < workers; i++ {
+	go s.runWriter()
+
+	for i := 0; i < s.minWorkers; i++ {
+		s.startWorker(0)
+	}
+
+	// Adaptive worker pool
+	if s.maxWorkers > s.minWorkers {
 		s.wg.Add(1)
-		go s.worker()
+		go s.runScaler()
+	}
+
+	// Spooled jobs, including those left from the previous run
+	if s.spool != nil {
+		go s.runFeeder()
+	} else {
+		close(s.feederDone)
 	}
 
 	// Clean soft-limit cache
 	go s.gcSoftLimitCache()
+
+	// History rollup and retention
+	if s.retentionInterval > 0 {
+		s.wg.Add(1)
+		go s.runRetention()
+	}
+
+	// Unclean shutdown detection
+	if s.sessionTimeout > 0 {
+		s.wg.Add(1)
+		go s.runSessions()
+	}
+
+	// Delayed A2S queries of servers that did not answer when reporting
+	if len(s.retryDelays) > 0 {
+		s.wg.Add(1)
+		go s.runA2SRetries()
+	}
+
+	// Live A2S data of known servers between their reports
+	if s.pollRate > 0 {
+		s.wg.Add(1)
+		go s.runPolling()
+	}
+
+	// Scheduled backups
+	if s.backupInterval > 0 {
+		s.wg.Add(1)
+		go s.runBackups()
+	}
 }
 
 // StopWorkers gracefully stops the background workers and closes the job queue.
+// Reports already processed by workers are flushed to the storage before it returns,
+// jobs still in the spool are kept for the next start.
 func (s *Server) StopWorkers() {
 	close(s.shutdown)
+	<-s.feederDone
 	close(s.queue)
 	s.wg.Wait()
+
+	// All workers are done, no more writes can arrive
+	close(s.writes)
+	<-s.writerDone
+
+	if s.spool != nil {
+		if err := s.spool.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing telemetry spool")
+		}
+	}
 }
 
 // Run configures the HTTP routes and returns the main handler.
 func (s *Server) Run() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.Handle("POST /api/telemetry", s.RateLimitMiddleware(http.HandlerFunc(s.handleTelemetry)))
+	// Single and batch reports share one hard rate limit per IP
+	telemetry := http.NewServeMux()
+	telemetry.HandleFunc("POST /api/telemetry", s.handleTelemetry)
+	telemetry.HandleFunc("POST /api/telemetry/batch", s.handleTelemetryBatch)
+	limited := s.RateLimitMiddleware(telemetry)
+	mux.Handle("POST /api/telemetry", limited)
+	mux.Handle("POST /api/telemetry/batch", limited)
 	mux.Handle("GET /api/stats", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleStats)))
+	mux.Handle("GET /api/nodes", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleListNodes)))
+	mux.Handle("GET /api/summary", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleSummary)))
 	mux.Handle("GET /api/a2s", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleServerQuery)))
+	mux.Handle("GET /api/nodes/{id}", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleGetNode)))
+	mux.Handle("DELETE /api/nodes/{id}", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleDeleteNode)))
+	mux.Handle("GET /api/nodes/{id}/history", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleNodeHistory)))
+	mux.Handle("GET /api/nodes/{id}/sessions", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleNodeSessions)))
+	mux.Handle("GET /api/nodes/{id}/uptime", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleNodeUptime)))
+	mux.Handle("GET /api/nodes/deleted", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleDeletedNodes)))
+	mux.Handle("POST /api/nodes/{id}/restore", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleRestoreNode)))
+	mux.Handle("GET /api/nodes/{id}/notes", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleGetAnnotation)))
+	mux.Handle("PUT /api/nodes/{id}/notes", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleSaveAnnotation)))
+	mux.Handle("DELETE /api/nodes/{id}/notes", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleDeleteAnnotation)))
+	mux.Handle("GET /api/tags", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleTags)))
+
+	// Legacy routes addressing nodes by app, ip and port query params
 	mux.Handle("GET /api/node", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleGetNode)))
 	mux.Handle("DELETE /api/node", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleDeleteNode)))
+	mux.Handle("GET /api/node/history", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleNodeHistory)))
+	mux.Handle("GET /api/backup", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleBackup)))
+	mux.Handle("GET /api/export", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleExport)))
+	mux.Handle("GET /api/versions/events", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleVersionEvents)))
+	mux.Handle("GET /api/versions/sessions", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleVersionSessions)))
+	mux.Handle("GET /api/versions/uptime", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleVersionUptime)))
+	mux.Handle("GET /api/activity", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleActivity)))
+	mux.Handle("GET /api/queue", AdminAuthMiddleware(s.authToken, http.HandlerFunc(s.handleQueueStats)))
 
 	fileServer := http.FileServer(assets.GetFileSystem())
 	mux.Handle("GET /js/", fileServer)
@@ -83,7 +247,8 @@ func (s *Server) Run() http.Handler {
 	return s.LoggingMiddleware(mux)
 }
 
-// gcSoftLimitCache periodically cleans up expired entries from the soft rate-limit cache.
+// gcSoftLimitCache periodically cleans up expired entries from the soft rate-limit cache
+// and remembered signatures.
 func (s *Server) gcSoftLimitCache() {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
@@ -104,6 +269,52 @@ func (s *Server) gcSoftLimitCache() {
 				}
 				return true
 			})
+			s.seenSignatures.Range(func(key, value interface{}) bool {
+				if t, ok := value.(time.Time); !ok || now.Sub(t) > s.signWindow {
+					s.seenSignatures.Delete(key)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// runRetention periodically rolls node history up into hourly and daily aggregates
+// and prunes data older than the configured retention policy.
+func (s *Server) runRetention() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.retentionInterval)
+	defer ticker.Stop()
+
+	s.applyRetention()
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			s.applyRetention()
 		}
 	}
 }
+
+// applyRetention executes a single retention pass and logs its outcome.
+func (s *Server) applyRetention() {
+	start := time.Now()
+	res, err := s.storage.ApplyRetention(s.retention, start)
+	if err != nil {
+		log.Error().Err(err).Msg("History retention failed")
+		return
+	}
+
+	log.Debug().
+		Int64("hourly_buckets", res.HourlyBuckets).
+		Int64("daily_buckets", res.DailyBuckets).
+		Int64("raw_deleted", res.RawDeleted).
+		Int64("hourly_deleted", res.HourlyDeleted).
+		Int64("daily_deleted", res.DailyDeleted).
+		Int64("activity_deleted", res.ActivityDeleted).
+		Int64("nodes_purged", res.NodesPurged).
+		Dur("duration", time.Since(start)).
+		Msg("History retention applied")
+}
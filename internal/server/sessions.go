@@ -0,0 +1,51 @@
+package server
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// sessionCheckInterval is how often open sessions are checked for missing heartbeats.
+const sessionCheckInterval = time.Minute
+
+// validEvent reports whether a lifecycle event is known, an empty event is a plain report.
+func validEvent(event string) bool {
+	switch event {
+	case "", models.EventStart, models.EventHeartbeat, models.EventStop:
+		return true
+	}
+
+	return false
+}
+
+// boundaryEvent reports whether the event opens or closes a session,
+// such reports bypass the soft limit so no session boundary is lost.
+func boundaryEvent(event string) bool {
+	return event == models.EventStart || event == models.EventStop
+}
+
+// runSessions periodically closes sessions whose node stopped sending heartbeats as unclean.
+func (s *Server) runSessions() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(sessionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			closed, err := s.storage.CloseStaleSessions(time.Now().Add(-s.sessionTimeout))
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to close stale sessions")
+				continue
+			}
+			if closed > 0 {
+				log.Debug().Int64("sessions", closed).Msg("Stale sessions closed as unclean")
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/config"
+)
+
+const (
+	// headerTimestamp carries the Unix time in seconds the report was signed at.
+	headerTimestamp = "X-Zenit-Timestamp"
+
+	// headerSignature carries comma separated "sha256=<hex>" signatures of the report,
+	// one per application secret for batch reports of several signed applications.
+	headerSignature = "X-Zenit-Signature"
+
+	// signaturePrefix precedes every hex encoded HMAC-SHA256 signature.
+	signaturePrefix = "sha256="
+)
+
+// trust is the outcome of the signature check of a single telemetry report.
+type trust uint8
+
+const (
+	// trustKeep marks an unsigned report accepted without changing the verified mark of the node.
+	trustKeep trust = iota
+
+	// trustVerified marks a report signed with the application secret.
+	trustVerified
+
+	// trustUnverified marks an unsigned report of an application that has no secret or is flagged.
+	trustUnverified
+)
+
+var (
+	errUnsigned         = errors.New("report is not signed")
+	errSignatureExpired = errors.New("signature timestamp outside of window")
+	errSignatureReplay  = errors.New("signature already used")
+	errSignatureInvalid = errors.New("signature does not match")
+)
+
+// signatureClaim is a verified signature of a report, recorded once the report is accepted
+// to reject its replays. A zero claim belongs to an unsigned report.
+type signatureClaim struct {
+	signedAt time.Time
+	key      string
+}
+
+// signedReport holds the signature headers and raw body of a telemetry request.
+type signedReport struct {
+	timestamp  string
+	signatures []string
+	body       []byte
+}
+
+// newSignedReport reads the signature headers of r, body is the raw request body.
+func newSignedReport(r *http.Request, body []byte) signedReport {
+	sr := signedReport{timestamp: r.Header.Get(headerTimestamp), body: body}
+	for _, v := range strings.Split(r.Header.Get(headerSignature), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			sr.signatures = append(sr.signatures, v)
+		}
+	}
+
+	return sr
+}
+
+// sign returns the signature of a report body signed at timestamp:
+// hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the application secret.
+func sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkSignature decides how a report of app is trusted.
+// Reports of applications without a secret are accepted unverified.
+// A report carrying a signature header must match the application secret within the window
+// and must not have been accepted before, unsigned reports follow the unsigned policy of the application.
+// A verified signature is only recorded by claimSignatures, so a report skipped by the soft limit may be sent again.
+// A non-nil error means the report must be rejected.
+func (s *Server) checkSignature(app string, sr signedReport) (trust, signatureClaim, error) {
+	secret, ok := s.secrets[app]
+	if !ok {
+		return trustUnverified, signatureClaim{}, nil
+	}
+
+	if len(sr.signatures) == 0 {
+		policy, ok := s.unsigned[app]
+		if !ok {
+			policy = s.unsignedDefault
+		}
+
+		switch policy {
+		case config.UnsignedAccept:
+			return trustKeep, signatureClaim{}, nil
+		case config.UnsignedFlag:
+			return trustUnverified, signatureClaim{}, nil
+		default:
+			return trustUnverified, signatureClaim{}, errUnsigned
+		}
+	}
+
+	ts, err := strconv.ParseInt(sr.timestamp, 10, 64)
+	if err != nil {
+		return trustUnverified, signatureClaim{}, errSignatureExpired
+	}
+	signedAt := time.Unix(ts, 0)
+	if age := time.Since(signedAt); age > s.signWindow || age < -s.signWindow {
+		return trustUnverified, signatureClaim{}, errSignatureExpired
+	}
+
+	expected := sign(secret, sr.timestamp, sr.body)
+	for _, sig := range sr.signatures {
+		if !hmac.Equal([]byte(sig), []byte(expected)) {
+			continue
+		}
+
+		claim := signatureClaim{key: app + ":" + sig, signedAt: signedAt}
+		if _, used := s.seenSignatures.Load(claim.key); used {
+			return trustUnverified, signatureClaim{}, errSignatureReplay
+		}
+		return trustVerified, claim, nil
+	}
+
+	return trustUnverified, signatureClaim{}, errSignatureInvalid
+}
+
+// claimSignatures records the signatures of an accepted report until they leave the window.
+// It returns false and records none of them if any was recorded meanwhile by a concurrent replay.
+func (s *Server) claimSignatures(claims []signatureClaim) bool {
+	for i, c := range claims {
+		if c.key == "" {
+			continue
+		}
+		if _, used := s.seenSignatures.LoadOrStore(c.key, c.signedAt); used {
+			s.releaseSignatures(claims[:i])
+			return false
+		}
+	}
+
+	return true
+}
+
+// releaseSignatures forgets claimed signatures of a report that was dropped, so it may be sent again.
+func (s *Server) releaseSignatures(claims []signatureClaim) {
+	for _, c := range claims {
+		if c.key != "" {
+			s.seenSignatures.Delete(c.key)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/config"
+)
+
+func TestSign(t *testing.T) {
+	// printf '%s' '1700000000.{"application":"app"}' | openssl dgst -sha256 -hmac secret
+	want := signaturePrefix + "30fea1c6f92bfd8ab40ff3a0aff0f1dfce5a966bfc5e64abfe455d432dfe73c7"
+	if got := sign([]byte("secret"), "1700000000", []byte(`{"application":"app"}`)); got != want {
+		t.Errorf("sign() = %s, want %s", got, want)
+	}
+}
+
+func TestNewSignedReport(t *testing.T) {
+	r := httptest.NewRequest("POST", "/telemetry", nil)
+	r.Header.Set(headerTimestamp, "1700000000")
+	r.Header.Set(headerSignature, " sha256=aa, ,sha256=bb ")
+
+	sr := newSignedReport(r, []byte("body"))
+	if sr.timestamp != "1700000000" || string(sr.body) != "body" {
+		t.Errorf("timestamp, body = %q, %q", sr.timestamp, sr.body)
+	}
+	if want := []string{"sha256=aa", "sha256=bb"}; !reflect.DeepEqual(sr.signatures, want) {
+		t.Errorf("signatures = %q, want %q", sr.signatures, want)
+	}
+}
+
+// signingServer returns a server with secrets for app and other, rejecting unsigned reports of other.
+func signingServer() *Server {
+	return &Server{
+		secrets: map[string][]byte{
+			"app":   []byte("secret"),
+			"other": []byte("other-secret"),
+		},
+		unsigned:        map[string]string{"other": config.UnsignedReject},
+		unsignedDefault: config.UnsignedFlag,
+		signWindow:      5 * time.Minute,
+	}
+}
+
+// signedBy returns a report of body signed at signedAt with the secret of app on s.
+func signedBy(s *Server, app string, signedAt time.Time, body string) signedReport {
+	ts := strconv.FormatInt(signedAt.Unix(), 10)
+	return signedReport{
+		timestamp:  ts,
+		signatures: []string{sign(s.secrets[app], ts, []byte(body))},
+		body:       []byte(body),
+	}
+}
+
+func TestCheckSignature(t *testing.T) {
+	s := signingServer()
+	now := time.Now()
+	body := `{"application":"app"}`
+
+	tests := []struct {
+		name    string
+		app     string
+		report  signedReport
+		want    trust
+		wantErr error
+		claimed bool
+	}{
+		{name: "no secret", app: "free", report: signedBy(s, "app", now, body), want: trustUnverified},
+		{name: "unsigned default", app: "app", report: signedReport{body: []byte(body)}, want: trustUnverified},
+		{
+			name: "unsigned reject", app: "other", report: signedReport{body: []byte(body)},
+			want: trustUnverified, wantErr: errUnsigned,
+		},
+		{name: "valid", app: "app", report: signedBy(s, "app", now, body), want: trustVerified, claimed: true},
+		{
+			name: "valid within window", app: "app", report: signedBy(s, "app", now.Add(-4*time.Minute), body),
+			want: trustVerified, claimed: true,
+		},
+		{
+			name: "expired", app: "app", report: signedBy(s, "app", now.Add(-6*time.Minute), body),
+			want: trustUnverified, wantErr: errSignatureExpired,
+		},
+		{
+			name: "from future", app: "app", report: signedBy(s, "app", now.Add(6*time.Minute), body),
+			want: trustUnverified, wantErr: errSignatureExpired,
+		},
+		{
+			name: "bad timestamp", app: "app",
+			report: signedReport{timestamp: "soon", signatures: []string{"sha256=00"}, body: []byte(body)},
+			want:   trustUnverified, wantErr: errSignatureExpired,
+		},
+		{
+			name: "other secret", app: "app", report: signedBy(s, "other", now, body),
+			want: trustUnverified, wantErr: errSignatureInvalid,
+		},
+		{
+			name: "tampered body", app: "app",
+			report: func() signedReport {
+				sr := signedBy(s, "app", now, body)
+				sr.body = []byte(strings.Replace(body, "app", "ap", 1))
+				return sr
+			}(),
+			want: trustUnverified, wantErr: errSignatureInvalid,
+		},
+		{
+			name: "one of several signatures", app: "app",
+			report: func() signedReport {
+				sr := signedBy(s, "app", now, body)
+				sr.signatures = append([]string{signedBy(s, "other", now, body).signatures[0]}, sr.signatures...)
+				return sr
+			}(),
+			want: trustVerified, claimed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, claim, err := s.checkSignature(tt.app, tt.report)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkSignature() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+			if (claim.key != "") != tt.claimed {
+				t.Errorf("claim = %+v, want claimed %v", claim, tt.claimed)
+			}
+		})
+	}
+}
+
+func TestSignatureReplay(t *testing.T) {
+	s := signingServer()
+	sr := signedBy(s, "app", time.Now(), `{"application":"app"}`)
+
+	// A checked report that was not accepted, e.g. skipped by the soft limit, may be sent again
+	if _, _, err := s.checkSignature("app", sr); err != nil {
+		t.Fatalf("first check: %v", err)
+	}
+	_, claim, err := s.checkSignature("app", sr)
+	if err != nil {
+		t.Fatalf("check of an unaccepted report: %v", err)
+	}
+
+	if !s.claimSignatures([]signatureClaim{claim}) {
+		t.Fatal("claimSignatures() = false for a new signature")
+	}
+	if _, _, err := s.checkSignature("app", sr); !errors.Is(err, errSignatureReplay) {
+		t.Errorf("check of an accepted report error = %v, want %v", err, errSignatureReplay)
+	}
+	if s.claimSignatures([]signatureClaim{claim}) {
+		t.Error("claimSignatures() = true for a concurrent replay")
+	}
+
+	// The same signature of another application is not a replay
+	s.secrets["copy"] = s.secrets["app"]
+	if _, _, err := s.checkSignature("copy", sr); err != nil {
+		t.Errorf("check of another application: %v", err)
+	}
+
+	// A dropped report may be sent again
+	s.releaseSignatures([]signatureClaim{claim})
+	if _, _, err := s.checkSignature("app", sr); err != nil {
+		t.Errorf("check of a released report: %v", err)
+	}
+}
+
+func TestClaimSignaturesAllOrNone(t *testing.T) {
+	s := signingServer()
+	now := time.Now()
+	body := `{"applications":[]}`
+
+	_, a, _ := s.checkSignature("app", signedBy(s, "app", now, body))
+	_, b, _ := s.checkSignature("other", signedBy(s, "other", now, body))
+
+	if !s.claimSignatures([]signatureClaim{b}) {
+		t.Fatal("claimSignatures() = false for a new signature")
+	}
+
+	// The batch is a replay as a whole, its new signature must not stay recorded
+	if s.claimSignatures([]signatureClaim{a, {}, b}) {
+		t.Fatal("claimSignatures() = true with a recorded signature")
+	}
+	if _, used := s.seenSignatures.Load(a.key); used {
+		t.Error("signature of a rejected batch stayed recorded")
+	}
+	if v, _ := s.seenSignatures.Load(b.key); v != b.signedAt {
+		t.Errorf("recorded time = %v, want signing time %v", v, b.signedAt)
+	}
+}
@@ -2,11 +2,13 @@ package server
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/woozymasta/zenit/internal/config"
 	"github.com/woozymasta/zenit/internal/geoip"
 	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/spool"
 	"github.com/woozymasta/zenit/internal/storage"
 )
 
@@ -14,7 +16,7 @@ import (
 // to handle HTTP requests and background telemetry processing.
 type Server struct {
 	// storage provides access to the persistent database layer for reading and writing node data.
-	storage *storage.Repository
+	storage storage.Store
 
 	// geoip provides functionality for resolving IP addresses to country codes.
 	// It can be nil if the GeoIP database is not initialized.
@@ -28,6 +30,40 @@ type Server struct {
 	// to background workers for asynchronous processing.
 	queue chan telemetryJob
 
+	// spool keeps accepted jobs on disk until they are saved, so they survive restarts.
+	// When it is set, handlers push jobs to it and a feeder passes them on to the queue.
+	// It can be nil if the spool is disabled.
+	spool *spool.Spool
+
+	// feederDone is closed by the spool feeder after it stopped.
+	feederDone chan struct{}
+
+	// startedAt is when the server was created, queue counters count from it.
+	startedAt time.Time
+
+	// workers is the number of running telemetry workers, busy the number of them processing a job.
+	workers atomic.Int64
+	busy    atomic.Int64
+
+	// processed and dropped count telemetry jobs processed by workers and dropped because the queue was full.
+	processed atomic.Uint64
+	dropped   atomic.Uint64
+
+	// minWorkers is the number of telemetry workers always running.
+	minWorkers int
+
+	// maxWorkers is the number of workers the pool grows up to while the queue stays filled,
+	// workers added above minWorkers stop after being idle. Not above minWorkers disables growing.
+	maxWorkers int
+
+	// writes is a buffered channel passing processed reports from workers to the single writer,
+	// reports of one job are sent together and saved in the same transaction.
+	// When the writer falls behind, workers block on it and the queue fills up (backpressure).
+	writes chan writeJob
+
+	// writerDone is closed by the writer after the final flush.
+	writerDone chan struct{}
+
 	// shutdown is a signal channel used to broadcast a stop signal to all background workers
 	// during a graceful shutdown.
 	shutdown chan struct{}
@@ -36,19 +72,48 @@ type Server struct {
 	// It supports the "soft rate limit" logic to reduce unnecessary database writes.
 	seenCache sync.Map
 
+	// globalRules are the ingestion rules of applications without a policy, built from the global flags.
+	globalRules appRules
+
+	// rules holds the ingestion rules of applications with a policy, keyed by name.
+	rules map[string]appRules
+
+	// secrets holds the HMAC secrets of applications whose reports may be signed, keyed by name.
+	secrets map[string][]byte
+
+	// unsigned holds policies (config.UnsignedAccept, UnsignedFlag, UnsignedReject)
+	// for unsigned reports of applications with a secret, keyed by name.
+	unsigned map[string]string
+
+	// unsignedDefault is the policy of applications with a secret and no entry in unsigned.
+	unsignedDefault string
+
+	// signWindow is the max age of a signature timestamp, used signatures are remembered as long.
+	signWindow time.Duration
+
+	// seenSignatures holds accepted signatures per application until they leave signWindow, to reject replays.
+	seenSignatures sync.Map
+
 	// authToken is the secret token required to access administrative API endpoints
 	// (e.g., /api/stats, /dashboard).
 	authToken string
 
-	// expectedUA expected User-Agent string
-	expectedUA string
-
 	// expectedCT expected Content-Type header
 	expectedCT string
 
 	// a2sOptions holds configuration settings for querying game servers (e.g., timeouts, retries).
 	a2sOptions config.A2S
 
+	// pollRate is the number of known servers queried per minute in the background, zero disables polling.
+	pollRate int
+
+	// pollInterval is the min time between background queries of the same server.
+	pollInterval time.Duration
+
+	// retryDelays are the delays of A2S query retries after a failed query of a reporting server,
+	// the n-th retry waits for the n-th delay. Empty disables retries.
+	retryDelays []time.Duration
+
 	// wg is used to wait for all background workers to finish processing
 	// before the server shuts down completely.
 	wg sync.WaitGroup
@@ -64,16 +129,40 @@ type Server struct {
 	// hardLimitWin is the time window duration for the hard rate limiter.
 	hardLimitWin time.Duration
 
-	// softLimitDur is the duration for which a server update is ignored (skipped)
-	// if it was recently seen. This helps reduce load from frequent updates.
+	// writeBatchSize is the maximum number of reports written in one transaction.
+	writeBatchSize int
+
+	// writeInterval is the maximum time a report waits in the writer before it is flushed.
+	writeInterval time.Duration
+
+	// backupInterval is how often a scheduled backup is written, zero disables scheduled backups.
+	backupInterval time.Duration
+
+	// backupDir is the directory scheduled backups are written to.
+	backupDir string
+
+	// backupKeep is the number of scheduled backups kept after rotation, zero keeps all.
+	backupKeep int
+
+	// retentionInterval is how often node history is rolled up and pruned.
+	// Zero disables the retention job.
+	retentionInterval time.Duration
+
+	// retention defines how long raw snapshots and their aggregates are kept.
+	retention storage.RetentionPolicy
+
+	// sessionTimeout is how long an open session may go without a heartbeat before it is closed as unclean.
+	// Zero keeps sessions open until the next start or stop event.
+	sessionTimeout time.Duration
+
+	// softLimitDur is the longest duration for which a server update is ignored (skipped)
+	// if it was recently seen, among the global and application rules.
+	// Soft-limit cache entries older than it are dropped.
 	softLimitDur time.Duration
 
 	// trustProxy indicates whether the server should trust headers like X-Forwarded-For
 	// or CF-Connecting-IP when determining the client's real IP address.
 	trustProxy bool
-
-	// ignoreUA disable User-Agent validation entirely
-	ignoreUA bool
 }
 
 // telemetryJob represents a unit of work to be processed by background workers.
@@ -83,7 +172,44 @@ type telemetryJob struct {
 	// This address is used for both GeoIP location resolution and A2S server queries.
 	IP string
 
-	// Req contains the deserialized payload from the incoming HTTP request,
+	// Trust holds the signature check outcome of each entry of Reqs.
+	Trust []trust
+
+	// Reqs contains the deserialized payloads from the incoming HTTP request,
 	// including the application name, server port, and version information.
-	Req models.TelemetryRequest
+	// A single report has one entry, a batch report one per application,
+	// all entries share the port, type and event of the first one.
+	Reqs []models.TelemetryRequest
+
+	// Received is when the request was accepted, reports replayed from the spool keep it as their time.
+	Received time.Time
+
+	// ack acknowledges a spooled job once its reports are saved, nil for jobs not read from the spool.
+	ack func()
+}
+
+// done acknowledges the job if it was read from the spool.
+func (j telemetryJob) done() {
+	if j.ack != nil {
+		j.ack()
+	}
+}
+
+// writeJob holds the processed reports of a job handed to the writer.
+type writeJob struct {
+	// ack acknowledges the job after its reports are written, can be nil.
+	ack func()
+
+	// poll is the answer of an A2S query retry of a server, stored in all its nodes
+	// like a background poll instead of reports. Nil for report jobs.
+	poll *models.Node
+
+	reports []storage.NodeReport
+}
+
+// done acknowledges the job once its reports are saved.
+func (j writeJob) done() {
+	if j.ack != nil {
+		j.ack()
+	}
 }
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/woozymasta/zenit/internal/storage"
+)
+
+// runWriter is the single background goroutine writing processed reports to the storage.
+// Reports are grouped into one transaction, flushed when the batch is full or the write interval elapses.
+// Reports of one job are always flushed together and the job is acknowledged once they are saved.
+// It returns after the writes channel is closed and the remaining reports are flushed.
+func (s *Server) runWriter() {
+	defer close(s.writerDone)
+
+	ticker := time.NewTicker(s.writeInterval)
+	defer ticker.Stop()
+
+	var (
+		batch   = make([]writeJob, 0, s.writeBatchSize)
+		pending int
+	)
+	for {
+		select {
+		case job, ok := <-s.writes:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+
+			batch = append(batch, job)
+			pending += max(len(job.reports), 1)
+			if pending >= s.writeBatchSize {
+				s.flush(batch)
+				batch, pending = batch[:0], 0
+			}
+
+		case <-ticker.C:
+			s.flush(batch)
+			batch, pending = batch[:0], 0
+		}
+	}
+}
+
+// flush writes a batch of job reports in a single transaction.
+// If the transaction fails, jobs are retried one by one so a single bad report does not drop the batch.
+// Only saved jobs are acknowledged, a spooled job failing to save stays in the spool
+// and is replayed after the next start.
+func (s *Server) flush(batch []writeJob) {
+	if len(batch) == 0 {
+		return
+	}
+
+	// Retried A2S answers only refresh the nodes of a server, they are saved on their own
+	jobs := batch[:0:0]
+	for _, job := range batch {
+		if job.poll == nil {
+			jobs = append(jobs, job)
+			continue
+		}
+
+		if err := s.storage.SaveServerPoll(*job.poll, true); err != nil {
+			log.Error().
+				Err(err).
+				Str("ip", job.poll.IP).
+				Int("port", job.poll.Port).
+				Msg("Failed to save A2S query retry")
+			continue
+		}
+		job.done()
+	}
+	if len(jobs) == 0 {
+		return
+	}
+	batch = jobs
+
+	var all []storage.NodeReport
+	for _, job := range batch {
+		all = append(all, job.reports...)
+	}
+
+	start := time.Now()
+	err := s.storage.SaveReports(all)
+	if err == nil {
+		log.Debug().
+			Int("reports", len(all)).
+			Dur("took", time.Since(start)).
+			Msg("Telemetry batch saved")
+
+		for _, job := range batch {
+			job.done()
+		}
+		return
+	}
+
+	log.Warn().Err(err).Int("reports", len(all)).Msg("Failed to save telemetry batch, retrying one by one")
+
+	for _, job := range batch {
+		reports := job.reports
+		if err := s.storage.SaveReports(reports); err != nil {
+			log.Error().
+				Err(err).
+				Str("ip", reports[0].Node.IP).
+				Int("port", reports[0].Node.Port).
+				Int("reports", len(reports)).
+				Msg("Failed to save node to DB")
+			continue
+		}
+
+		job.done()
+	}
+}
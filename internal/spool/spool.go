@@ -0,0 +1,602 @@
+// Package spool implements a durable FIFO queue of records kept in append-only segment files,
+// so queued records survive restarts and crashes of the process.
+//
+// Records are appended as lines to the newest segment and read in order from the oldest one.
+// Read records are acknowledged once processed, the position before the first unacknowledged
+// record is saved as a checkpoint every second and fully processed segments are removed.
+// After a crash records read but not acknowledged within the last second are delivered again.
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// segmentExt is the file name extension of segment files, named by their zero padded number.
+	segmentExt = ".seg"
+
+	// checkpointName is the file holding the position of the first unacknowledged record.
+	checkpointName = "checkpoint"
+
+	// maxSegmentSize is the size a segment grows to before a new one is started.
+	// A spool with a size limit uses a quarter of the limit if it is smaller,
+	// so dropping the oldest segment can make room while the head segment is written.
+	maxSegmentSize = 4 << 20
+
+	// syncInterval is how often written records are flushed to disk and the checkpoint is saved.
+	syncInterval = time.Second
+)
+
+var (
+	// ErrFull is returned by Push when the spool reached its size limit and the record is dropped.
+	ErrFull = errors.New("spool is full")
+
+	// ErrClosed is returned by Push after the spool is closed.
+	ErrClosed = errors.New("spool is closed")
+)
+
+// Record is a single queued record read from the spool.
+type Record struct {
+	// Data is the record as it was pushed.
+	Data []byte
+
+	// Seq identifies the record when it is acknowledged.
+	Seq uint64
+}
+
+// position addresses a byte offset within a segment.
+type position struct {
+	seg uint64
+	off int64
+}
+
+// Spool is a durable FIFO queue, safe for concurrent use.
+type Spool struct {
+	// head is the append handle of the newest segment.
+	head *os.File
+
+	// reader reads the segment at read, nil until the next read opens it.
+	reader *os.File
+	rbuf   *bufio.Reader
+
+	// ends holds the end position of read records by sequence number until they are acknowledged,
+	// acked marks acknowledged records not yet covered by the checkpoint.
+	ends  map[uint64]position
+	acked map[uint64]bool
+
+	// sizes holds the size of every segment on disk by number.
+	sizes map[uint64]int64
+
+	// notify wakes a waiting reader after a push or close.
+	notify chan struct{}
+
+	// stop ends the sync loop, synced is closed when it returned.
+	stop   chan struct{}
+	synced chan struct{}
+
+	dir string
+
+	// segments lists segment numbers on disk, oldest first, the last one is the head.
+	segments []uint64
+
+	// read is the position of the next record to read.
+	read position
+
+	// checkpoint is the position before the first unacknowledged record, saved is its last saved value.
+	checkpoint position
+	saved      position
+
+	// maxSize limits pending bytes, records pushed beyond it are dropped.
+	maxSize int64
+
+	// segmentSize is the size a segment grows to before a new one is started.
+	segmentSize int64
+
+	// pending is the number of bytes pushed but not read yet.
+	pending int64
+
+	// nextSeq is the sequence number of the next read record,
+	// ackSeq the first one not yet covered by the checkpoint.
+	nextSeq uint64
+	ackSeq  uint64
+
+	mu sync.Mutex
+
+	// dropOld discards the oldest unread segment instead of the pushed record when the spool is full.
+	dropOld bool
+
+	// dirty marks records written to the head since the last sync.
+	dirty bool
+
+	closed bool
+}
+
+// Open opens the spool in dir, creating it if needed, and resumes after the saved checkpoint.
+// A corrupt checkpoint is ignored and reading starts from the oldest segment.
+// maxSize limits the bytes of unread records, zero is unlimited. When the limit is reached
+// Push drops the pushed record, or with dropOld the oldest unread segment if there is one.
+func Open(dir string, maxSize int64, dropOld bool) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	s := &Spool{
+		dir:         dir,
+		maxSize:     maxSize,
+		segmentSize: maxSegmentSize,
+		dropOld:     dropOld,
+		ends:        make(map[uint64]position),
+		acked:       make(map[uint64]bool),
+		sizes:       make(map[uint64]int64),
+		notify:      make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		synced:      make(chan struct{}),
+	}
+	if maxSize > 0 {
+		s.segmentSize = min(s.segmentSize, max(maxSize/4, 1))
+	}
+
+	segments, err := s.listSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := s.loadCheckpoint()
+	if err != nil {
+		// Reading from the oldest segment delivers processed records again but loses none
+		log.Warn().Err(err).Str("dir", dir).Msg("Ignored spool checkpoint, reading from the oldest segment")
+		saved = position{}
+	}
+
+	// Segments before the checkpoint are fully processed
+	for len(segments) > 0 && segments[0] < saved.seg {
+		if err := os.Remove(s.segmentPath(segments[0])); err != nil {
+			return nil, err
+		}
+		segments = segments[1:]
+	}
+
+	// Numbers keep growing so a stale checkpoint never points past new segments
+	if len(segments) == 0 {
+		segments = []uint64{max(saved.seg, 1)}
+	}
+	s.segments = segments
+
+	for _, seg := range segments {
+		info, err := os.Stat(s.segmentPath(seg))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			s.sizes[seg] = info.Size()
+		}
+	}
+
+	if err := s.openHead(); err != nil {
+		return nil, err
+	}
+
+	checkpoint := saved
+	if checkpoint.seg != segments[0] {
+		checkpoint = position{seg: segments[0]}
+	}
+	checkpoint.off = min(checkpoint.off, s.sizes[checkpoint.seg])
+	s.read, s.checkpoint, s.saved = checkpoint, checkpoint, saved
+
+	for _, seg := range segments {
+		if seg >= s.read.seg {
+			s.pending += s.sizes[seg]
+		}
+	}
+	s.pending -= s.read.off
+
+	go s.syncLoop()
+
+	return s, nil
+}
+
+// Push appends a record to the spool, the record must not contain a newline.
+func (s *Spool) Push(data []byte) error {
+	if bytes.IndexByte(data, '\n') >= 0 {
+		return errors.New("record contains a newline")
+	}
+	size := int64(len(data)) + 1
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	if s.maxSize > 0 && s.pending+size > s.maxSize {
+		for s.dropOld && s.pending+size > s.maxSize && s.read.seg != s.headSeg() {
+			s.skipSegment()
+		}
+		if s.pending+size > s.maxSize {
+			return ErrFull
+		}
+	}
+
+	headSeg := s.headSeg()
+	if s.sizes[headSeg] > 0 && s.sizes[headSeg]+size > s.segmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		headSeg = s.headSeg()
+	}
+
+	line := make([]byte, 0, size)
+	if _, err := s.head.Write(append(append(line, data...), '\n')); err != nil {
+		return err
+	}
+	s.sizes[headSeg] += size
+	s.pending += size
+	s.dirty = true
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Pop returns the next record, waiting for one to be pushed.
+// It returns false once done is closed or the spool is closed.
+func (s *Spool) Pop(done <-chan struct{}) (Record, bool, error) {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return Record{}, false, nil
+		}
+		rec, ok, err := s.next()
+		s.mu.Unlock()
+
+		if ok || err != nil {
+			return rec, ok, err
+		}
+
+		select {
+		case <-s.notify:
+		case <-done:
+			return Record{}, false, nil
+		}
+	}
+}
+
+// Ack marks a record returned by Pop as processed.
+func (s *Spool) Ack(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.acked[seq] = true
+	s.advance()
+}
+
+// Pending returns the number of bytes pushed but not read yet.
+func (s *Spool) Pending() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pending
+}
+
+// Close flushes the spool to disk, saves the checkpoint and releases its files.
+// Records not acknowledged before are delivered again when the spool is opened next time.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	<-s.synced
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.sync()
+	if s.reader != nil {
+		_ = s.reader.Close()
+	}
+	if cerr := s.head.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// next reads the next complete record, false if all pushed records are read.
+// The caller holds the lock.
+func (s *Spool) next() (Record, bool, error) {
+	for {
+		headSeg := s.headSeg()
+		if s.read.seg == headSeg && s.read.off >= s.sizes[headSeg] {
+			return Record{}, false, nil
+		}
+
+		if s.reader == nil {
+			f, err := os.Open(s.segmentPath(s.read.seg))
+			if err != nil {
+				return Record{}, false, err
+			}
+			if _, err := f.Seek(s.read.off, io.SeekStart); err != nil {
+				_ = f.Close()
+				return Record{}, false, err
+			}
+			s.reader, s.rbuf = f, bufio.NewReader(f)
+		}
+
+		line, err := s.rbuf.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return Record{}, false, err
+		}
+		s.read.off += int64(len(line))
+		s.pending -= int64(len(line))
+
+		if err == io.EOF {
+			// Older segments are complete, a torn record at their end is skipped
+			if s.read.seg != headSeg {
+				s.nextSegment()
+			}
+			continue
+		}
+
+		seq := s.nextSeq
+		s.nextSeq++
+		s.ends[seq] = s.read
+
+		return Record{Data: line[:len(line)-1], Seq: seq}, true, nil
+	}
+}
+
+// nextSegment moves reading to the start of the segment after the current one.
+func (s *Spool) nextSegment() {
+	if s.reader != nil {
+		_ = s.reader.Close()
+		s.reader, s.rbuf = nil, nil
+	}
+
+	for _, seg := range s.segments {
+		if seg > s.read.seg {
+			s.read = position{seg: seg}
+			return
+		}
+	}
+}
+
+// skipSegment drops unread records of the segment being read and moves on to the next one.
+// The skipped records are treated as read and acknowledged, so they are not delivered after a restart.
+func (s *Spool) skipSegment() {
+	s.pending -= s.sizes[s.read.seg] - s.read.off
+	s.nextSegment()
+
+	seq := s.nextSeq
+	s.nextSeq++
+	s.ends[seq] = s.read
+	s.acked[seq] = true
+	s.advance()
+}
+
+// advance moves the checkpoint past contiguous acknowledged records.
+func (s *Spool) advance() {
+	for s.acked[s.ackSeq] {
+		s.checkpoint = s.ends[s.ackSeq]
+		delete(s.acked, s.ackSeq)
+		delete(s.ends, s.ackSeq)
+		s.ackSeq++
+	}
+}
+
+// rotate syncs and closes the head segment and starts a new one.
+func (s *Spool) rotate() error {
+	if err := s.head.Sync(); err != nil {
+		return err
+	}
+	if err := s.head.Close(); err != nil {
+		return err
+	}
+	s.dirty = false
+
+	s.segments = append(s.segments, s.headSeg()+1)
+
+	return s.openHead()
+}
+
+// openHead opens the newest segment for appending, cutting off a record torn by a crash.
+func (s *Spool) openHead() error {
+	seg := s.headSeg()
+	f, err := os.OpenFile(s.segmentPath(seg), os.O_RDWR|os.O_CREATE, 0o640)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	size := int64(bytes.LastIndexByte(data, '\n') + 1)
+	if size != int64(len(data)) {
+		if err := f.Truncate(size); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	s.head = f
+	s.sizes[seg] = size
+
+	return nil
+}
+
+// syncLoop periodically flushes written records, saves the checkpoint and removes processed segments.
+func (s *Spool) syncLoop() {
+	defer close(s.synced)
+
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			_ = s.sync()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// sync flushes the head segment, saves a changed checkpoint and removes segments before it.
+// The caller holds the lock.
+func (s *Spool) sync() error {
+	if s.dirty {
+		if err := s.head.Sync(); err != nil {
+			return err
+		}
+		s.dirty = false
+	}
+
+	if s.checkpoint == s.saved {
+		return nil
+	}
+	if err := s.saveCheckpoint(s.checkpoint); err != nil {
+		return err
+	}
+	s.saved = s.checkpoint
+
+	for len(s.segments) > 1 && s.segments[0] < s.checkpoint.seg && s.segments[0] < s.read.seg {
+		if err := os.Remove(s.segmentPath(s.segments[0])); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(s.sizes, s.segments[0])
+		s.segments = s.segments[1:]
+	}
+
+	return nil
+}
+
+// headSeg returns the number of the newest segment.
+func (s *Spool) headSeg() uint64 {
+	return s.segments[len(s.segments)-1]
+}
+
+// segmentPath returns the file path of a segment.
+func (s *Spool) segmentPath(seg uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seg, segmentExt))
+}
+
+// listSegments returns numbers of segment files in the spool directory, oldest first.
+func (s *Spool) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		seg, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || seg == 0 {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+
+	return segments, nil
+}
+
+// loadCheckpoint reads the saved checkpoint, zero if there is none.
+func (s *Spool) loadCheckpoint() (position, error) {
+	var p position
+
+	data, err := os.ReadFile(filepath.Join(s.dir, checkpointName))
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+
+	if _, err := fmt.Sscanf(string(data), "%d %d", &p.seg, &p.off); err != nil {
+		return position{}, fmt.Errorf("invalid spool checkpoint: %w", err)
+	}
+
+	return p, nil
+}
+
+// saveCheckpoint atomically and durably replaces the checkpoint file.
+func (s *Spool) saveCheckpoint(p position) error {
+	path := filepath.Join(s.dir, checkpointName)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%d %d\n", p.seg, p.off); err != nil {
+		_ = f.Close()
+		return err
+	}
+	// The content must reach the disk before the rename, or a crash may leave an empty checkpoint
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	return syncDir(s.dir)
+}
+
+// syncDir flushes directory entries, making a rename in the directory durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+
+	return d.Close()
+}
@@ -0,0 +1,356 @@
+package spool
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// closedDone makes Pop return at once when no record is pending.
+var closedDone = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+func openSpool(t *testing.T, dir string, maxSize int64, dropOld bool) *Spool {
+	t.Helper()
+
+	s, err := Open(dir, maxSize, dropOld)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	return s
+}
+
+func push(t *testing.T, s *Spool, records ...string) {
+	t.Helper()
+
+	for _, r := range records {
+		if err := s.Push([]byte(r)); err != nil {
+			t.Fatalf("Push(%.16q): %v", r, err)
+		}
+	}
+}
+
+// pop reads the next record, failing if there is none.
+func pop(t *testing.T, s *Spool) Record {
+	t.Helper()
+
+	rec, ok, err := s.Pop(closedDone)
+	if err != nil {
+		t.Fatalf("Pop: %v", err)
+	}
+	if !ok {
+		t.Fatal("Pop: no record")
+	}
+
+	return rec
+}
+
+// expectRecords pops records and compares them with want, then expects the spool to be drained.
+func expectRecords(t *testing.T, s *Spool, ack bool, want ...string) {
+	t.Helper()
+
+	for _, w := range want {
+		rec := pop(t, s)
+		if string(rec.Data) != w {
+			t.Fatalf("Pop = %.16q, want %.16q", rec.Data, w)
+		}
+		if ack {
+			s.Ack(rec.Seq)
+		}
+	}
+
+	if rec, ok, err := s.Pop(closedDone); ok || err != nil {
+		t.Fatalf("Pop = %.16q, %v, %v, want no record", rec.Data, ok, err)
+	}
+}
+
+// segmentFiles returns the segment files in dir.
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+// megabyteRecord returns a record of 1 MiB of c, four of them do not fit into one segment.
+func megabyteRecord(c byte) string {
+	return string(bytes.Repeat([]byte{c}, 1<<20))
+}
+
+func TestPushPopAck(t *testing.T) {
+	s := openSpool(t, t.TempDir(), 0, false)
+	defer func() { _ = s.Close() }()
+
+	push(t, s, "a", "bb", "ccc")
+	if got := s.Pending(); got != 9 {
+		t.Errorf("Pending = %d, want 9", got)
+	}
+
+	var seqs []uint64
+	for _, want := range []string{"a", "bb", "ccc"} {
+		rec := pop(t, s)
+		if string(rec.Data) != want {
+			t.Errorf("Pop = %q, want %q", rec.Data, want)
+		}
+		seqs = append(seqs, rec.Seq)
+	}
+	if seqs[0] == seqs[1] || seqs[1] == seqs[2] {
+		t.Errorf("sequence numbers %v are not unique", seqs)
+	}
+	if got := s.Pending(); got != 0 {
+		t.Errorf("Pending = %d after reading all, want 0", got)
+	}
+
+	// Acknowledged out of order, the checkpoint follows once the gap is closed
+	s.Ack(seqs[2])
+	s.Ack(seqs[0])
+	if s.checkpoint.off != 2 {
+		t.Errorf("checkpoint offset = %d, want 2 after the first record", s.checkpoint.off)
+	}
+	s.Ack(seqs[1])
+	if s.checkpoint.off != 9 {
+		t.Errorf("checkpoint offset = %d, want 9 after all records", s.checkpoint.off)
+	}
+
+	expectRecords(t, s, true)
+
+	if err := s.Push([]byte("two\nlines")); err == nil {
+		t.Error("Push accepted a record with a newline")
+	}
+}
+
+func TestPopWaitsForPush(t *testing.T) {
+	s := openSpool(t, t.TempDir(), 0, false)
+	defer func() { _ = s.Close() }()
+
+	got := make(chan string, 1)
+	go func() {
+		rec, ok, err := s.Pop(make(chan struct{}))
+		if err != nil || !ok {
+			got <- ""
+			return
+		}
+		got <- string(rec.Data)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	push(t, s, "late")
+
+	select {
+	case r := <-got:
+		if r != "late" {
+			t.Errorf("Pop = %q, want %q", r, "late")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pop did not return after Push")
+	}
+}
+
+func TestClose(t *testing.T) {
+	s := openSpool(t, t.TempDir(), 0, false)
+
+	done := make(chan bool, 1)
+	go func() {
+		_, ok, _ := s.Pop(make(chan struct{}))
+		done <- ok
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("Pop returned a record after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pop did not return after Close")
+	}
+
+	if err := s.Push([]byte("a")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Push after Close error = %v, want %v", err, ErrClosed)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestReplayAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openSpool(t, dir, 0, false)
+	push(t, s, "a", "b", "c")
+	first := pop(t, s)
+	pop(t, s)
+	s.Ack(first.Seq)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The read but unacknowledged record is delivered again
+	s = openSpool(t, dir, 0, false)
+	if got := s.Pending(); got != 4 {
+		t.Errorf("Pending after reopen = %d, want 4", got)
+	}
+	expectRecords(t, s, true, "b", "c")
+	push(t, s, "d")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s = openSpool(t, dir, 0, false)
+	defer func() { _ = s.Close() }()
+	expectRecords(t, s, true, "d")
+}
+
+func TestTruncatedRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openSpool(t, dir, 0, false)
+	push(t, s, "a", "b")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A crash in the middle of a write leaves a record without its newline
+	files := segmentFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("got %d segments, want 1", len(files))
+	}
+	f, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"torn":`); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	s = openSpool(t, dir, 0, false)
+	defer func() { _ = s.Close() }()
+
+	if info, err := os.Stat(files[0]); err != nil || info.Size() != 4 {
+		t.Errorf("segment size = %v, %v, want the torn record cut off", info.Size(), err)
+	}
+	push(t, s, "c")
+	expectRecords(t, s, true, "a", "b", "c")
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	records := []string{
+		megabyteRecord('a'), megabyteRecord('b'), megabyteRecord('c'),
+		megabyteRecord('d'), megabyteRecord('e'),
+	}
+
+	s := openSpool(t, dir, 0, false)
+	push(t, s, records...)
+	if got := len(segmentFiles(t, dir)); got != 2 {
+		t.Fatalf("got %d segments, want 2", got)
+	}
+
+	// Records are read across segments in order, a processed segment is removed on sync
+	for _, want := range records[:4] {
+		rec := pop(t, s)
+		if string(rec.Data) != want {
+			t.Fatalf("Pop = %.16q, want %.16q", rec.Data, want)
+		}
+		s.Ack(rec.Seq)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := len(segmentFiles(t, dir)); got != 1 {
+		t.Errorf("got %d segments after processing the first one, want 1", got)
+	}
+
+	s = openSpool(t, dir, 0, false)
+	defer func() { _ = s.Close() }()
+	expectRecords(t, s, true, records[4])
+}
+
+func TestFullDropNew(t *testing.T) {
+	s := openSpool(t, t.TempDir(), 10, false)
+	defer func() { _ = s.Close() }()
+
+	push(t, s, "aaaa", "bbbb")
+	if err := s.Push([]byte("c")); !errors.Is(err, ErrFull) {
+		t.Fatalf("Push to a full spool error = %v, want %v", err, ErrFull)
+	}
+
+	// Reading frees space
+	expectRecords(t, s, true, "aaaa", "bbbb")
+	push(t, s, "cccc")
+	expectRecords(t, s, true, "cccc")
+}
+
+func TestFullDropOld(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c, d, e := megabyteRecord('a'), megabyteRecord('b'), megabyteRecord('c'),
+		megabyteRecord('d'), megabyteRecord('e')
+
+	// Segments are a quarter of the limit, every record gets its own one
+	s := openSpool(t, dir, 5<<20, true)
+	push(t, s, a, b, c, d)
+
+	// The oldest segment holding a is dropped for e
+	push(t, s, e)
+	if got := s.Pending(); got != 4*(1<<20+1) {
+		t.Errorf("Pending = %d, want 4 records", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Dropped records are not delivered again after a restart
+	s = openSpool(t, dir, 5<<20, true)
+	defer func() { _ = s.Close() }()
+	expectRecords(t, s, true, b, c, d, e)
+}
+
+func TestFullDropOldSmall(t *testing.T) {
+	s := openSpool(t, t.TempDir(), 10, true)
+	defer func() { _ = s.Close() }()
+
+	// Segments are a quarter of the limit, so the oldest record can be dropped
+	push(t, s, "aaaa", "bbbb", "c")
+	expectRecords(t, s, true, "bbbb", "c")
+
+	// A record larger than the limit is never stored
+	if err := s.Push([]byte("dddddddddd")); !errors.Is(err, ErrFull) {
+		t.Fatalf("Push of a record over the limit error = %v, want %v", err, ErrFull)
+	}
+}
+
+func TestCorruptCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openSpool(t, dir, 0, false)
+	push(t, s, "a", "b")
+	expectRecords(t, s, true, "a", "b")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, checkpointName), []byte("garbage"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records are delivered again from the oldest segment rather than lost
+	s = openSpool(t, dir, 0, false)
+	defer func() { _ = s.Close() }()
+	expectRecords(t, s, true, "a", "b")
+}
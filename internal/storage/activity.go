@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// Active node windows in days, the day itself included.
+const (
+	weekWindow  = 7
+	monthWindow = 30
+)
+
+// activityInsert marks a node active on a day, the node is resolved by (Application, IP, Port).
+const activityInsert = `
+	INSERT INTO node_activity (day, application, node_id)
+	VALUES (?, ?, (SELECT id FROM nodes WHERE application = ? AND ip = ? AND port = ?))
+	ON CONFLICT(application, day, node_id) DO NOTHING
+	`
+
+// activityDay returns the UTC day a report time is counted in.
+func activityDay(t time.Time) time.Time {
+	return truncateDay(t.UTC())
+}
+
+// GetActivity computes DAU, WAU and MAU for every UTC day between from and to, per application.
+// If appName is provided (not empty), it restricts results to that application.
+func (r *Repository) GetActivity(appName string, from, to time.Time) ([]models.AppActivity, error) {
+	from, to = activityDay(from), activityDay(to)
+	days := int(to.Sub(from)/(24*time.Hour)) + 1
+
+	// Monthly windows of the first days reach back before the range
+	query := `
+		SELECT application, node_id, day
+		FROM node_activity
+		WHERE day >= ? AND day <= ?
+	`
+	args := []interface{}{from.AddDate(0, 0, -(monthWindow - 1)), to}
+	if appName != "" {
+		query += " AND application = ?"
+		args = append(args, appName)
+	}
+	query += " ORDER BY application, node_id, day"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	// Active days of a node as offsets from the range start, rows are sorted so offsets ascend
+	type nodeKey struct {
+		app string
+		id  int64
+	}
+	active := make(map[nodeKey][]int)
+	for rows.Next() {
+		var (
+			k   nodeKey
+			day time.Time
+		)
+		if err := rows.Scan(&k.app, &k.id, &day); err != nil {
+			continue
+		}
+		offset := int(activityDay(day).Sub(from) / (24 * time.Hour))
+		active[k] = append(active[k], offset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	apps := make(map[string]*models.AppActivity)
+	for k, offsets := range active {
+		a, ok := apps[k.app]
+		if !ok {
+			a = &models.AppActivity{Application: k.app, Days: make([]models.ActivityDay, days)}
+			for i := range a.Days {
+				a.Days[i].Day = from.AddDate(0, 0, i)
+			}
+			apps[k.app] = a
+		}
+
+		// A node counts once per day in every window covering one of its active days
+		weekCovered, monthCovered := -1, -1
+		for _, d := range offsets {
+			if d >= 0 && d < days {
+				a.Days[d].DAU++
+			}
+			weekCovered = cover(a.Days, d, weekWindow, weekCovered, func(ad *models.ActivityDay) { ad.WAU++ })
+			monthCovered = cover(a.Days, d, monthWindow, monthCovered, func(ad *models.ActivityDay) { ad.MAU++ })
+		}
+	}
+
+	result := make([]models.AppActivity, 0, len(apps))
+	for _, a := range apps {
+		last := a.Days[len(a.Days)-1]
+		a.DAU, a.WAU, a.MAU = last.DAU, last.WAU, last.MAU
+		if a.MAU > 0 {
+			a.Stickiness = float64(a.DAU) / float64(a.MAU)
+		}
+		result = append(result, *a)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Application < result[j].Application })
+
+	return result, nil
+}
+
+// cover applies inc to days within the window starting at day d that are after covered,
+// and returns the last covered day. Days outside the range are skipped.
+func cover(days []models.ActivityDay, d, window, covered int, inc func(*models.ActivityDay)) int {
+	end := d + window - 1
+	for i := max(d, covered+1, 0); i <= end && i < len(days); i++ {
+		inc(&days[i])
+	}
+
+	return max(end, covered)
+}
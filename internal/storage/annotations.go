@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// GetAnnotation retrieves notes, owner contact and tags of a node.
+// A node without annotations yields an empty annotation.
+func (r *Repository) GetAnnotation(nodeID int64) (*models.NodeAnnotation, error) {
+	a := &models.NodeAnnotation{NodeID: nodeID}
+
+	var (
+		notes, contact sql.NullString
+		updatedAt      sql.NullTime
+	)
+	err := r.db.QueryRow(
+		`SELECT notes, owner_contact, updated_at FROM node_annotations WHERE node_id = ?`, nodeID,
+	).Scan(&notes, &contact, &updatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	a.Notes, a.Contact, a.UpdatedAt = notes.String, contact.String, updatedAt.Time
+
+	tags, err := r.nodeTags(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	a.Tags = tags
+	if a.Tags == nil {
+		a.Tags = []string{}
+	}
+
+	return a, nil
+}
+
+// SaveAnnotation replaces notes, owner contact and tags of a node in a single transaction.
+func (r *Repository) SaveAnnotation(a models.NodeAnnotation) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO node_annotations (node_id, notes, owner_contact, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(node_id) DO UPDATE SET
+			notes         = excluded.notes,
+			owner_contact = excluded.owner_contact,
+			updated_at    = excluded.updated_at
+	`, a.NodeID, a.Notes, a.Contact, a.UpdatedAt); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM node_tags WHERE node_id = ?`, a.NodeID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, tag := range a.Tags {
+		if _, err := tx.Exec(`INSERT INTO node_tags (node_id, tag) VALUES (?, ?)`, a.NodeID, tag); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteAnnotation removes notes, owner contact and tags of a node.
+func (r *Repository) DeleteAnnotation(nodeID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM node_tags WHERE node_id = ?`,
+		`DELETE FROM node_annotations WHERE node_id = ?`,
+	} {
+		if _, err := tx.Exec(query, nodeID); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTags retrieves all tags in use by not deleted nodes with the number of tagged nodes,
+// most used first.
+func (r *Repository) GetTags() ([]models.GroupCount, error) {
+	rows, err := r.db.Query(`
+		SELECT t.tag, COUNT(*)
+		FROM node_tags t
+		JOIN nodes n ON n.id = t.node_id
+		WHERE n.deleted_at IS NULL
+		GROUP BY t.tag
+		ORDER BY COUNT(*) DESC, t.tag ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var tags []models.GroupCount
+	for rows.Next() {
+		var c models.GroupCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			continue
+		}
+		tags = append(tags, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// nodeTags retrieves tags of a single node in alphabetical order.
+func (r *Repository) nodeTags(nodeID int64) ([]string, error) {
+	rows, err := r.db.Query(`SELECT tag FROM node_tags WHERE node_id = ? ORDER BY tag`, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// attachTags fills Tags of the given nodes.
+func (r *Repository) attachTags(nodes []models.Node) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	tags, err := r.allTags()
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		nodes[i].Tags = tags[nodes[i].ID]
+	}
+
+	return nil
+}
+
+// allTags retrieves tags of all nodes keyed by node ID, in alphabetical order.
+// Tags are maintained by hand and few, so they are all read at once.
+func (r *Repository) allTags() (map[int64][]string, error) {
+	rows, err := r.db.Query(`SELECT node_id, tag FROM node_tags ORDER BY tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var (
+			nodeID int64
+			tag    string
+		)
+		if err := rows.Scan(&nodeID, &tag); err != nil {
+			return nil, err
+		}
+		tags[nodeID] = append(tags[nodeID], tag)
+	}
+
+	return tags, rows.Err()
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrBackupUnsupported is returned by Backup when the backend has no built-in online backup.
+var ErrBackupUnsupported = errors.New("online backup is supported only for SQLite, use pg_dump for PostgreSQL")
+
+// Backup writes a consistent snapshot of the database into a new file at path using VACUUM INTO.
+// It is safe to run while the database is in use, the target file must not exist.
+func (r *Repository) Backup(path string) error {
+	if r.db.dialect.name != dialectSQLite.name {
+		return ErrBackupUnsupported
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup target %s already exists", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	_, err := r.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// NodeReport is a single processed telemetry report waiting to be written.
+type NodeReport struct {
+	Node models.Node
+
+	// Online marks whether the A2S query succeeded, it is stored with the snapshot.
+	Online bool
+
+	// Event is the lifecycle event of the report applied to the node sessions, empty for none.
+	Event string
+
+	// UptimeInterval is the expected interval between reports of the node, zero skips uptime tracking.
+	UptimeInterval time.Duration
+
+	// RetryAt schedules an A2S query retry of the server after a failed query, zero for none.
+	// A report with Online set cancels a scheduled retry.
+	RetryAt time.Time
+
+	// KeepVerified keeps the stored verified mark of the node instead of Node.Verified,
+	// set for unsigned reports accepted without changing it.
+	KeepVerified bool
+}
+
+// SaveReports upserts the nodes of all reports, appends their snapshots,
+// marks them active for the day, applies their lifecycle events, extends their uptime
+// and schedules A2S query retries in a single transaction.
+// Either all reports are saved or none of them.
+func (r *Repository) SaveReports(reports []NodeReport) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, rep := range reports {
+		n := rep.Node
+		nodeID, err := upsertNode(tx, n, rep.KeepVerified)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(snapshotInsert,
+			n.Application, n.IP, n.Port,
+			n.LastSeen, n.Version, n.MapName, n.GameVersion, n.Players, n.MaxPlayers, rep.Online,
+		); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(activityInsert,
+			activityDay(n.LastSeen), n.Application, n.Application, n.IP, n.Port,
+		); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if err := recordSession(tx, n, rep.Event); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if rep.UptimeInterval > 0 {
+			if err := recordUptime(tx, nodeID, n, rep.UptimeInterval); err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+
+		if err := recordRetry(tx, rep); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a malformed cursor or one issued for another sorting.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// nodeCursor is the position after the last node of a page: the sort key, direction
+// and the values of the ORDER BY columns of that node.
+type nodeCursor struct {
+	Sort   string        `json:"sort"`
+	Values []cursorValue `json:"values"`
+	Desc   bool          `json:"desc"`
+}
+
+// cursorValue keeps the type of a sort column value, so it is bound as stored.
+// All fields are nil for NULL.
+type cursorValue struct {
+	Time *time.Time `json:"t,omitempty"`
+	Int  *int64     `json:"i,omitempty"`
+	Text *string    `json:"s,omitempty"`
+}
+
+// value returns the value to bind in a query, nil for NULL.
+func (v cursorValue) value() interface{} {
+	switch {
+	case v.Time != nil:
+		return *v.Time
+	case v.Int != nil:
+		return *v.Int
+	case v.Text != nil:
+		return *v.Text
+	default:
+		return nil
+	}
+}
+
+// encodeCursor returns the opaque token of a cursor, values are scanned sort column values.
+func encodeCursor(c nodeCursor, values []interface{}) (string, error) {
+	c.Values = make([]cursorValue, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+		case time.Time:
+			c.Values[i].Time = &v
+		case int64:
+			c.Values[i].Int = &v
+		case int32:
+			n := int64(v)
+			c.Values[i].Int = &n
+		case int16:
+			n := int64(v)
+			c.Values[i].Int = &n
+		case string:
+			c.Values[i].Text = &v
+		case []byte:
+			s := string(v)
+			c.Values[i].Text = &s
+		default:
+			return "", errors.New("unsupported cursor value type")
+		}
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses a token returned by encodeCursor.
+func decodeCursor(token string) (nodeCursor, error) {
+	var c nodeCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+
+	return c, err
+}
+
+// sortScanner scans a node row followed by its sort column values.
+type sortScanner struct {
+	rowScanner
+	values []interface{}
+}
+
+// Scan scans the node columns into dest and the remaining sort columns into values.
+func (s sortScanner) Scan(dest ...interface{}) error {
+	for i := range s.values {
+		dest = append(dest, &s.values[i])
+	}
+
+	return s.rowScanner.Scan(dest...)
+}
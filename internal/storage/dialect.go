@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dialect describes the differences between supported SQL backends.
+// Queries are always written with "?" placeholders and rewritten when required.
+type dialect struct {
+	// name is the backend name, also used as the migrations subdirectory.
+	name string
+
+	// migrationTable is the DDL of the schema_migrations tracking table.
+	migrationTable string
+
+	// truncateHour and truncateDay are formats of SQL expressions truncating the time in column %[1]s
+	// to its UTC hour or day, in the same form as a bound time.
+	truncateHour string
+	truncateDay  string
+
+	// numbered enables PostgreSQL-style "$1, $2, ..." placeholders.
+	numbered bool
+}
+
+var (
+	dialectSQLite = dialect{
+		name: "sqlite",
+		migrationTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at DATETIME,
+			checksum TEXT
+		);`,
+		// Times are stored as UTC text such as "2026-10-16 12:34:56.789 +0000 UTC"
+		truncateHour: `substr(%[1]s, 1, 13) || ':00:00 +0000 UTC'`,
+		truncateDay:  `substr(%[1]s, 1, 10) || ' 00:00:00 +0000 UTC'`,
+	}
+
+	dialectPostgres = dialect{
+		name:     "postgres",
+		numbered: true,
+		migrationTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ,
+			checksum TEXT
+		);`,
+		truncateHour: `date_trunc('hour', %[1]s AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'`,
+		truncateDay:  `date_trunc('day', %[1]s AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'`,
+	}
+)
+
+// rebind rewrites "?" placeholders into the form expected by the dialect.
+func (d dialect) rebind(query string) string {
+	if !d.numbered || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' {
+			b.WriteByte(query[i])
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+// truncate returns an SQL expression truncating the time in column to its UTC hour,
+// or to its UTC day when period is a day or longer.
+func (d dialect) truncate(column string, period time.Duration) string {
+	format := d.truncateHour
+	if period >= 24*time.Hour {
+		format = d.truncateDay
+	}
+
+	return fmt.Sprintf(format, column)
+}
+
+// timeScanner scans a time computed by an SQL expression such as dialect.truncate into t.
+// SQLite returns such times as text in the stored form, PostgreSQL as time values.
+type timeScanner struct {
+	t *time.Time
+}
+
+// Scan implements sql.Scanner.
+func (s timeScanner) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case time.Time:
+		*s.t = v.UTC()
+		return nil
+	case string:
+		return s.parse(v)
+	case []byte:
+		return s.parse(string(v))
+	default:
+		return fmt.Errorf("unsupported time value %T", src)
+	}
+}
+
+// parse parses a time stored by SQLite, as formatted by time.Time.String without the monotonic clock.
+func (s timeScanner) parse(v string) error {
+	t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", v)
+	if err != nil {
+		return err
+	}
+	*s.t = t.UTC()
+
+	return nil
+}
+
+// utcArgs returns query arguments with times converted to UTC.
+// SQLite stores times as text and compares them as strings, which only orders them
+// when all share one zone and carry no monotonic clock reading, so every bound time is normalized.
+func utcArgs(args []interface{}) []interface{} {
+	var out []interface{}
+	for i, arg := range args {
+		var utc interface{}
+		switch v := arg.(type) {
+		case time.Time:
+			utc = v.UTC()
+		case *time.Time:
+			if v == nil {
+				continue
+			}
+			utc = v.UTC()
+		case sql.NullTime:
+			if !v.Valid {
+				continue
+			}
+			utc = sql.NullTime{Time: v.Time.UTC(), Valid: true}
+		default:
+			continue
+		}
+
+		// Copy on first change, callers may reuse their argument slices
+		if out == nil {
+			out = append([]interface{}(nil), args...)
+		}
+		out[i] = utc
+	}
+
+	if out == nil {
+		return args
+	}
+
+	return out
+}
+
+// db wraps sql.DB, rebinds query placeholders for the active dialect and binds times in UTC.
+type db struct {
+	*sql.DB
+	dialect dialect
+}
+
+// Exec executes a query without returning any rows.
+func (d *db) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.DB.Exec(d.dialect.rebind(query), utcArgs(args)...)
+}
+
+// Query executes a query that returns rows.
+func (d *db) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.DB.Query(d.dialect.rebind(query), utcArgs(args)...)
+}
+
+// QueryRow executes a query that is expected to return at most one row.
+func (d *db) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.DB.QueryRow(d.dialect.rebind(query), utcArgs(args)...)
+}
+
+// Begin starts a transaction bound to the same dialect.
+func (d *db) Begin() (*tx, error) {
+	t, err := d.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &tx{Tx: t, dialect: d.dialect}, nil
+}
+
+// tx wraps sql.Tx, rebinds query placeholders for the active dialect and binds times in UTC.
+type tx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+// Exec executes a query without returning any rows within the transaction.
+func (t *tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.rebind(query), utcArgs(args)...)
+}
+
+// Query executes a query that returns rows within the transaction.
+func (t *tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.rebind(query), utcArgs(args)...)
+}
+
+// QueryRow executes a query that is expected to return at most one row within the transaction.
+func (t *tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.rebind(query), utcArgs(args)...)
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// Kinds of custom field values stored in node_extra.kind.
+const (
+	extraString = "string"
+	extraNumber = "number"
+	extraBool   = "bool"
+)
+
+// extraIDChunk is the maximum number of node IDs in a single IN list when loading custom fields.
+const extraIDChunk = 500
+
+// encodeExtra converts a custom field value into its stored text form and kind.
+// Numbers are written without exponent or trailing zeros, so filters match them as typed.
+func encodeExtra(v interface{}) (string, string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, extraString, nil
+	case bool:
+		return strconv.FormatBool(v), extraBool, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), extraNumber, nil
+	case int:
+		return strconv.Itoa(v), extraNumber, nil
+	case int64:
+		return strconv.FormatInt(v, 10), extraNumber, nil
+	default:
+		return "", "", fmt.Errorf("unsupported extra value type %T", v)
+	}
+}
+
+// decodeExtra converts a stored custom field back into its JSON typed value.
+func decodeExtra(value, kind string) interface{} {
+	switch kind {
+	case extraNumber:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case extraBool:
+		return value == "true"
+	}
+
+	return value
+}
+
+// replaceExtra replaces all custom fields of a node within an existing transaction.
+// A nil map keeps the stored fields, an empty map clears them.
+func replaceExtra(t *tx, nodeID int64, extra map[string]interface{}) error {
+	if extra == nil {
+		return nil
+	}
+
+	if _, err := t.Exec(`DELETE FROM node_extra WHERE node_id = ?`, nodeID); err != nil {
+		return err
+	}
+
+	for key, v := range extra {
+		value, kind, err := encodeExtra(v)
+		if err != nil {
+			return fmt.Errorf("extra %q: %w", key, err)
+		}
+		if _, err := t.Exec(
+			`INSERT INTO node_extra (node_id, key, value, kind) VALUES (?, ?, ?, ?)`, nodeID, key, value, kind,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nodeExtra retrieves custom fields of a single node, nil if it has none.
+func (r *Repository) nodeExtra(nodeID int64) (map[string]interface{}, error) {
+	extra, err := r.extraOf([]int64{nodeID})
+	if err != nil {
+		return nil, err
+	}
+
+	return extra[nodeID], nil
+}
+
+// attachExtra fills Extra of the given nodes.
+func (r *Repository) attachExtra(nodes []models.Node) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+
+	extra, err := r.extraOf(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		nodes[i].Extra = extra[nodes[i].ID]
+	}
+
+	return nil
+}
+
+// extraOf retrieves custom fields of the given nodes keyed by node ID.
+// Custom fields are reported by every node, so unlike tags they are read only for the requested nodes.
+func (r *Repository) extraOf(ids []int64) (map[int64]map[string]interface{}, error) {
+	extra := make(map[int64]map[string]interface{})
+
+	for start := 0; start < len(ids); start += extraIDChunk {
+		chunk := ids[start:min(start+extraIDChunk, len(ids))]
+
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", ")
+
+		if err := r.scanExtra(extra, `
+			SELECT node_id, key, value, kind FROM node_extra WHERE node_id IN (`+placeholders+`)
+		`, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	return extra, nil
+}
+
+// allExtra retrieves custom fields of all nodes keyed by node ID.
+func (r *Repository) allExtra() (map[int64]map[string]interface{}, error) {
+	extra := make(map[int64]map[string]interface{})
+	if err := r.scanExtra(extra, `SELECT node_id, key, value, kind FROM node_extra`); err != nil {
+		return nil, err
+	}
+
+	return extra, nil
+}
+
+// scanExtra runs a query selecting node_id, key, value and kind and collects the rows into extra.
+func (r *Repository) scanExtra(extra map[int64]map[string]interface{}, query string, args ...interface{}) error {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var (
+			nodeID           int64
+			key, value, kind string
+		)
+		if err := rows.Scan(&nodeID, &key, &value, &kind); err != nil {
+			return err
+		}
+
+		if extra[nodeID] == nil {
+			extra[nodeID] = make(map[string]interface{})
+		}
+		extra[nodeID][key] = decodeExtra(value, kind)
+	}
+
+	return rows.Err()
+}
+
+// extraGroupCounts counts nodes matching where per value of the custom field key, most common first.
+// Nodes without the field are counted under an empty name.
+func (r *Repository) extraGroupCounts(key, where string, args []interface{}) ([]models.GroupCount, error) {
+	args = append([]interface{}{key}, args...)
+
+	rows, err := r.db.Query(`
+		SELECT COALESCE(e.value, ''), COUNT(*)
+		FROM nodes
+		LEFT JOIN node_extra e ON e.node_id = nodes.id AND e.key = ?`+where+`
+		GROUP BY COALESCE(e.value, '')
+		ORDER BY COUNT(*) DESC, COALESCE(e.value, '') ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := []models.GroupCount{}
+	for rows.Next() {
+		var c models.GroupCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// extraConditions builds filter conditions matching nodes whose custom fields equal the given values,
+// in key order so the same filter always yields the same query.
+func extraConditions(extra map[string]string) ([]string, []interface{}) {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conds := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, len(keys)*2)
+	for _, key := range keys {
+		conds = append(conds, "id IN (SELECT node_id FROM node_extra WHERE key = ? AND value = ?)")
+		args = append(args, key, extra[key])
+	}
+
+	return conds, args
+}
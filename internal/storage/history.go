@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// AddSnapshot appends the current state of a node to its history.
+// The node is resolved by its unique identifier (Application, IP, Port), so it must be upserted first.
+// The snapshot timestamp is taken from LastSeen; online marks whether the A2S query succeeded.
+func (r *Repository) AddSnapshot(n models.Node, online bool) error {
+	_, err := r.db.Exec(snapshotInsert,
+		n.Application, n.IP, n.Port,
+		n.LastSeen, n.Version, n.MapName, n.GameVersion, n.Players, n.MaxPlayers, online,
+	)
+
+	return err
+}
+
+// snapshotInsert appends a node snapshot, the node is resolved by (Application, IP, Port).
+const snapshotInsert = `
+	INSERT INTO node_snapshots (
+		node_id, seen_at, version, map_name, game_version, players, max_players, online
+	)
+	VALUES ((SELECT id FROM nodes WHERE application = ? AND ip = ? AND port = ?), ?, ?, ?, ?, ?, ?, ?)
+	`
+
+// GetNodeHistory retrieves snapshots of a specific node recorded between from and to, oldest first.
+func (r *Repository) GetNodeHistory(app, ip string, port int, from, to time.Time) ([]models.NodeSnapshot, error) {
+	rows, err := r.db.Query(`
+		SELECT s.seen_at, s.version, s.map_name, s.game_version, s.players, s.max_players, s.online
+		FROM node_snapshots s
+		JOIN nodes n ON n.id = s.node_id
+		WHERE n.application = ? AND n.ip = ? AND n.port = ?
+		  AND s.seen_at >= ? AND s.seen_at <= ?
+		ORDER BY s.seen_at ASC
+	`, app, ip, port, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var snapshots []models.NodeSnapshot
+	for rows.Next() {
+		var s models.NodeSnapshot
+		if err := rows.Scan(
+			&s.Time, &s.Version, &s.MapName, &s.GameVersion, &s.Players, &s.MaxPlayers, &s.Online,
+		); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
@@ -1,10 +1,13 @@
 package storage
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,71 +15,368 @@ import (
 	"github.com/woozymasta/zenit/assets"
 )
 
-// runMigrations checks for new SQL files in embedded assets and applies them.
-func runMigrations(db *sql.DB) error {
-	// Create table to track migrations history
-	const migrationTableSchema = `
-	CREATE TABLE IF NOT EXISTS schema_migrations (
-		version TEXT PRIMARY KEY,
-		applied_at DATETIME
-	);`
+// downSuffix marks the file reverting the migration with the same name prefix,
+// e.g. 00002_node_snapshots.down.sql reverts 00002_node_snapshots.sql.
+const downSuffix = ".down.sql"
 
-	if _, err := db.Exec(migrationTableSchema); err != nil {
-		return fmt.Errorf("failed to create migration table: %w", err)
+// MigrationState describes a schema migration embedded in the binary or recorded in the database.
+type MigrationState struct {
+	AppliedAt time.Time
+	Version   string
+	Applied   bool
+
+	// Modified reports that the embedded migration differs from the one applied.
+	Modified bool
+
+	// Reversible reports that a down migration is available.
+	Reversible bool
+
+	// Unknown reports an applied migration missing from this binary, e.g. applied by a newer release.
+	Unknown bool
+}
+
+// migration is an embedded schema migration with its optional down migration.
+type migration struct {
+	version  string // up file name, recorded in schema_migrations
+	up       string
+	down     string
+	checksum string
+	number   int
+}
+
+// appliedMigration is a row of schema_migrations.
+type appliedMigration struct {
+	appliedAt time.Time
+	checksum  string
+}
+
+// runMigrations checks for new SQL files of the database dialect in embedded assets and applies them.
+func runMigrations(db *db) error {
+	if err := prepareMigrationTable(db); err != nil {
+		return err
 	}
 
-	// Get list of migration files
-	entries, err := assets.ReadDir("migrations")
+	migrations, err := loadMigrations(db.dialect)
 	if err != nil {
-		return fmt.Errorf("failed to read migrations dir: %w", err)
+		return err
 	}
 
-	// Filter and sort .sql files
-	var files []string
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
-			files = append(files, entry.Name())
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]struct{}, len(migrations))
+	for _, m := range migrations {
+		known[m.version] = struct{}{}
+
+		a, ok := applied[m.version]
+		if !ok {
+			if err := applyMigration(db, m); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch a.checksum {
+		case "":
+			// Applied before checksums were recorded
+			if _, err := db.Exec(
+				"UPDATE schema_migrations SET checksum = ? WHERE version = ?", m.checksum, m.version,
+			); err != nil {
+				return fmt.Errorf("failed to record checksum of migration %s: %w", m.version, err)
+			}
+		case m.checksum:
+		default:
+			log.Warn().Str("file", m.version).Msg("Applied database migration was modified since it was applied")
 		}
 	}
-	sort.Strings(files)
 
-	// Apply migrations
-	for _, file := range files {
-		var exists int
-		err := db.QueryRow("SELECT 1 FROM schema_migrations WHERE version = ?", file).Scan(&exists)
-		if err == nil {
-			continue // applied
-		} else if err != sql.ErrNoRows {
-			return fmt.Errorf("failed to check migration status: %w", err)
+	for version := range applied {
+		if _, ok := known[version]; !ok {
+			log.Warn().Str("file", version).Msg("Database has a migration unknown to this version, it was applied by a newer release")
 		}
+	}
+
+	return nil
+}
 
-		log.Info().Str("file", file).Msg("Applying database migration...")
+// MigrationStatus lists embedded and applied schema migrations ordered by version, without applying anything.
+func (r *Repository) MigrationStatus() ([]MigrationState, error) {
+	if err := prepareMigrationTable(r.db); err != nil {
+		return nil, err
+	}
 
-		content, err := assets.ReadFile(filepath.ToSlash(filepath.Join("migrations", file)))
-		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", file, err)
+	migrations, err := loadMigrations(r.db.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationState{Version: m.version, Reversible: m.down != ""}
+		if a, ok := applied[m.version]; ok {
+			st.Applied = true
+			st.AppliedAt = a.appliedAt
+			st.Modified = a.checksum != "" && a.checksum != m.checksum
+			delete(applied, m.version)
 		}
+		states = append(states, st)
+	}
 
-		// Execute migration in transaction
-		tx, err := db.Begin()
-		if err != nil {
-			return err
+	for version, a := range applied {
+		states = append(states, MigrationState{Version: version, Applied: true, AppliedAt: a.appliedAt, Unknown: true})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+
+	return states, nil
+}
+
+// MigrateTo applies or rolls back schema migrations until version is the latest applied one.
+// version is a migration number ("3", "00003") or file name ("00003_history_rollups.sql"),
+// "0" rolls back all migrations. Rolled back migrations must have a down migration.
+// With dryRun the planned steps are only logged.
+func (r *Repository) MigrateTo(version string, dryRun bool) error {
+	target, err := migrationNumber(version)
+	if err != nil {
+		return err
+	}
+
+	if err := prepareMigrationTable(r.db); err != nil {
+		return err
+	}
+
+	migrations, err := loadMigrations(r.db.dialect)
+	if err != nil {
+		return err
+	}
+
+	found := target == 0
+	known := make(map[string]struct{}, len(migrations))
+	for _, m := range migrations {
+		known[m.version] = struct{}{}
+		if m.number == target {
+			found = true
 		}
+	}
+	if !found {
+		return fmt.Errorf("unknown migration version %q", version)
+	}
+
+	applied, err := appliedMigrations(r.db)
+	if err != nil {
+		return err
+	}
 
-		if _, err := tx.Exec(string(content)); err != nil {
-			_ = tx.Rollback()
-			return fmt.Errorf("failed to exec migration %s: %w", file, err)
+	for v := range applied {
+		n, err := migrationNumber(v)
+		if _, ok := known[v]; !ok && (err != nil || n > target) {
+			return fmt.Errorf("migration %s is unknown to this version, roll it back with the release that applied it", v)
 		}
+	}
 
-		if _, err := tx.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", file, time.Now()); err != nil {
-			_ = tx.Rollback()
-			return fmt.Errorf("failed to record migration %s: %w", file, err)
+	// Roll back newest first
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok || m.number <= target {
+			continue
+		}
+		if m.down == "" {
+			return fmt.Errorf("migration %s has no down migration", m.version)
+		}
+		if dryRun {
+			log.Info().Str("file", m.version).Msg("Would revert database migration")
+			continue
 		}
+		if err := revertMigration(r.db, m); err != nil {
+			return err
+		}
+	}
 
-		if err := tx.Commit(); err != nil {
+	// Apply oldest first
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok || m.number > target {
+			continue
+		}
+		if dryRun {
+			log.Info().Str("file", m.version).Msg("Would apply database migration")
+			continue
+		}
+		if err := applyMigration(r.db, m); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// prepareMigrationTable creates the migrations tracking table
+// and adds the checksum column to tables created by older releases.
+func prepareMigrationTable(db *db) error {
+	if _, err := db.Exec(db.dialect.migrationTable); err != nil {
+		return fmt.Errorf("failed to create migration table: %w", err)
+	}
+
+	var checksum sql.NullString
+	err := db.QueryRow("SELECT checksum FROM schema_migrations LIMIT 1").Scan(&checksum)
+	if err == nil || err == sql.ErrNoRows {
+		return nil
+	}
+
+	if _, err := db.Exec("ALTER TABLE schema_migrations ADD COLUMN checksum TEXT"); err != nil {
+		return fmt.Errorf("failed to add checksum to migration table: %w", err)
+	}
+
+	return nil
+}
+
+// loadMigrations reads embedded migrations of the dialect, sorted by version.
+func loadMigrations(d dialect) ([]migration, error) {
+	dir := filepath.ToSlash(filepath.Join("migrations", d.name))
+	entries, err := assets.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir: %w", err)
+	}
+
+	read := func(name string) (string, error) {
+		content, err := assets.ReadFile(filepath.ToSlash(filepath.Join(dir, name)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read migration file %s: %w", name, err)
+		}
+		return string(content), nil
+	}
+
+	downs := make(map[string]string)
+	var migrations []migration
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		content, err := read(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasSuffix(name, downSuffix) {
+			downs[strings.TrimSuffix(name, downSuffix)+".sql"] = content
+			continue
+		}
+
+		number, err := migrationNumber(name)
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256([]byte(content))
+		migrations = append(migrations, migration{
+			version:  name,
+			number:   number,
+			up:       content,
+			checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	for i := range migrations {
+		migrations[i].down = downs[migrations[i].version]
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	return migrations, nil
+}
+
+// appliedMigrations returns the rows of schema_migrations keyed by version.
+func appliedMigrations(db *db) (map[string]appliedMigration, error) {
+	rows, err := db.Query("SELECT version, applied_at, checksum FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migration status: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := make(map[string]appliedMigration)
+	for rows.Next() {
+		var (
+			version   string
+			appliedAt sql.NullTime
+			checksum  sql.NullString
+		)
+		if err := rows.Scan(&version, &appliedAt, &checksum); err != nil {
+			return nil, fmt.Errorf("failed to check migration status: %w", err)
+		}
+		applied[version] = appliedMigration{appliedAt: appliedAt.Time, checksum: checksum.String}
+	}
+
+	return applied, rows.Err()
+}
+
+// applyMigration executes an up migration and records it in a single transaction.
+func applyMigration(db *db, m migration) error {
+	log.Info().Str("file", m.version).Msg("Applying database migration...")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(m.up); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to exec migration %s: %w", m.version, err)
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO schema_migrations (version, applied_at, checksum) VALUES (?, ?, ?)",
+		m.version, time.Now(), m.checksum,
+	); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to record migration %s: %w", m.version, err)
+	}
+
+	return tx.Commit()
+}
+
+// revertMigration executes a down migration and removes its record in a single transaction.
+func revertMigration(db *db, m migration) error {
+	if m.down == "" {
+		return fmt.Errorf("migration %s has no down migration", m.version)
+	}
+
+	log.Info().Str("file", m.version).Msg("Reverting database migration...")
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(m.down); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to revert migration %s: %w", m.version, err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.version); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("failed to remove migration record %s: %w", m.version, err)
+	}
+
+	return tx.Commit()
+}
+
+// migrationNumber parses the leading number of a migration version or file name.
+func migrationNumber(version string) (int, error) {
+	digits := version
+	if i := strings.IndexFunc(version, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		digits = version[:i]
+	}
+
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid migration version %q", version)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/semver"
+)
+
+// nodeColumns lists the nodes table columns in the order expected by scanNode.
+const nodeColumns = `id, application, ip, port, version, country_code, type,
+		       server_name, map_name, players, max_players, game_version, game_name, server_os,
+		       count, first_seen, last_seen, deleted_at, verified`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanNode scans a row selected with nodeColumns into n.
+func scanNode(row rowScanner, n *models.Node) error {
+	var deletedAt sql.NullTime
+	if err := row.Scan(
+		&n.ID, &n.Application, &n.IP, &n.Port, &n.Version, &n.CountryCode, &n.Type,
+		&n.ServerName, &n.MapName, &n.Players, &n.MaxPlayers, &n.GameVersion, &n.GameName, &n.ServerOS,
+		&n.Count, &n.FirstSeen, &n.LastSeen, &deletedAt, &n.Verified,
+	); err != nil {
+		return err
+	}
+
+	n.DeletedAt = nil
+	if deletedAt.Valid {
+		n.DeletedAt = &deletedAt.Time
+	}
+
+	return nil
+}
+
+// UpsertNode inserts a new node or updates an existing one based on the Application, IP, and Port constraint.
+// It handles logic for updating fields only when they are non-empty or changed,
+// and records a version event when the node is new or reports a different version.
+// Custom fields are replaced when the node reports them and kept otherwise,
+// the verified mark is kept as it is only set by signed telemetry reports.
+// A soft-deleted node that reports again is restored.
+func (r *Repository) UpsertNode(n models.Node) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := upsertNode(tx, n, true); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// upsertNode performs UpsertNode within an existing transaction and returns the ID of the node.
+// Unless keepVerified is set, the verified mark of the node is replaced with n.Verified.
+func upsertNode(t *tx, n models.Node, keepVerified bool) (int64, error) {
+	var oldVersion string
+	err := t.QueryRow(
+		`SELECT version FROM nodes WHERE application = ? AND ip = ? AND port = ?`,
+		n.Application, n.IP, n.Port,
+	).Scan(&oldVersion)
+	exists := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	query := `
+	INSERT INTO nodes (
+		application, ip, port, version, country_code, type,
+		server_name, map_name, players, max_players, game_version, game_name, server_os,
+		count, first_seen, last_seen, verified, online, queried_at, ` + semverColumns + `
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(application, ip, port) DO UPDATE SET
+		count = nodes.count + 1,
+		last_seen = excluded.last_seen,
+		deleted_at = NULL,
+		verified = CASE WHEN ? THEN nodes.verified ELSE excluded.verified END,
+		version = excluded.version,
+		version_major = excluded.version_major,
+		version_minor = excluded.version_minor,
+		version_patch = excluded.version_patch,
+		version_pre = excluded.version_pre,
+		type = excluded.type,
+
+		-- Update country if updated and not blank
+		country_code = CASE WHEN excluded.country_code != '' THEN excluded.country_code ELSE nodes.country_code END,
+
+		-- Update A2S fields only if updated
+		server_name  = CASE WHEN excluded.server_name != '' THEN excluded.server_name ELSE nodes.server_name END,
+		map_name     = CASE WHEN excluded.server_name != '' THEN excluded.map_name ELSE nodes.map_name END,
+		players      = CASE WHEN excluded.server_name != '' THEN excluded.players ELSE nodes.players END,
+		max_players  = CASE WHEN excluded.server_name != '' THEN excluded.max_players ELSE nodes.max_players END,
+		game_version = CASE WHEN excluded.server_name != '' THEN excluded.game_version ELSE nodes.game_version END,
+		game_name    = CASE WHEN excluded.server_name != '' THEN excluded.game_name ELSE nodes.game_name END,
+		server_os    = CASE WHEN excluded.server_name != '' THEN excluded.server_os ELSE nodes.server_os END,
+		online       = CASE WHEN excluded.server_name != '' THEN excluded.online ELSE nodes.online END,
+		queried_at   = CASE WHEN excluded.server_name != '' THEN excluded.queried_at ELSE nodes.queried_at END
+	RETURNING id;
+	`
+
+	// A2S data marks the node online as queried now
+	online := n.ServerName != ""
+	var queriedAt interface{}
+	if online {
+		queriedAt = n.LastSeen
+	}
+
+	// Use LastSeen and for FirstSeen when insert new record
+	args := []interface{}{
+		n.Application, n.IP, n.Port, n.Version, n.CountryCode, n.Type,
+		n.ServerName, n.MapName, n.Players, n.MaxPlayers, n.GameVersion, n.GameName, n.ServerOS,
+		n.FirstSeen, n.LastSeen, n.Verified, online, queriedAt,
+	}
+	args = append(args, versionArgs(n.Version)...)
+	args = append(args, keepVerified)
+
+	var id int64
+	if err := t.QueryRow(query, args...).Scan(&id); err != nil {
+		return 0, err
+	}
+
+	// Versions stored before they were normalized are not a change
+	oldNormalized, _ := semver.Normalize(oldVersion)
+	newNormalized, _ := semver.Normalize(n.Version)
+	if !exists || oldNormalized != newNormalized {
+		if _, err := t.Exec(`
+			INSERT INTO version_events (node_id, application, old_version, new_version, changed_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, id, n.Application, oldVersion, n.Version, n.LastSeen); err != nil {
+			return 0, err
+		}
+	}
+
+	if n.Extra != nil {
+		if err := replaceExtra(t, id, n.Extra); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// GetNodes retrieves all not deleted nodes from the database, sorted by the last seen timestamp in descending order.
+func (r *Repository) GetNodes() ([]models.Node, error) {
+	rows, err := r.db.Query(`
+		SELECT ` + nodeColumns + `
+		FROM nodes
+		WHERE deleted_at IS NULL
+		ORDER BY last_seen DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var nodes []models.Node
+	for rows.Next() {
+		var n models.Node
+		if err := scanNode(rows, &n); err != nil {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// GetNode retrieves a specific not deleted node by its unique identifier (Application, IP, Port).
+func (r *Repository) GetNode(app, ip string, port int) (*models.Node, error) {
+	query := `
+		SELECT ` + nodeColumns + `
+		FROM nodes
+		WHERE application = ? AND ip = ? AND port = ? AND deleted_at IS NULL
+	`
+
+	return r.getNode(r.db.QueryRow(query, app, ip, port))
+}
+
+// GetNodeByID retrieves a specific not deleted node by its database ID.
+func (r *Repository) GetNodeByID(id int64) (*models.Node, error) {
+	query := `
+		SELECT ` + nodeColumns + `
+		FROM nodes
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	return r.getNode(r.db.QueryRow(query, id))
+}
+
+// getNode scans a single node row and loads its tags and custom fields, returning nil if there is no row.
+func (r *Repository) getNode(row *sql.Row) (*models.Node, error) {
+	var n models.Node
+	err := scanNode(row, &n)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Not found
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Tags, err = r.nodeTags(n.ID); err != nil {
+		return nil, err
+	}
+	if n.Extra, err = r.nodeExtra(n.ID); err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+// DeleteEmptyNodes soft-deletes records that have empty A2S data (server_name is empty).
+// If appName is provided (not empty), it restricts deletion to that application.
+func (r *Repository) DeleteEmptyNodes(appName string) (int64, error) {
+	query := `
+		UPDATE nodes SET deleted_at = ?
+		WHERE deleted_at IS NULL AND (server_name IS NULL OR server_name = '')`
+	args := []interface{}{time.Now()}
+
+	if appName != "" {
+		query += ` AND application = ?`
+		args = append(args, appName)
+	}
+
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteNode soft-deletes a specific node identified by app, ip, and port.
+func (r *Repository) DeleteNode(app, ip string, port int) error {
+	query := `UPDATE nodes SET deleted_at = ? WHERE application = ? AND ip = ? AND port = ? AND deleted_at IS NULL`
+	_, err := r.db.Exec(query, time.Now(), app, ip, port)
+	return err
+}
+
+// DeleteNodeByID soft-deletes a specific node by its database ID.
+func (r *Repository) DeleteNodeByID(id int64) error {
+	_, err := r.db.Exec(`UPDATE nodes SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, time.Now(), id)
+	return err
+}
+
+// GetDeletedNodes retrieves soft-deleted nodes, most recently deleted first.
+func (r *Repository) GetDeletedNodes() ([]models.Node, error) {
+	rows, err := r.db.Query(`
+		SELECT ` + nodeColumns + `
+		FROM nodes
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var nodes []models.Node
+	for rows.Next() {
+		var n models.Node
+		if err := scanNode(rows, &n); err != nil {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// RestoreNode clears the tombstone of a soft-deleted node, it returns false if no deleted node has the ID.
+func (r *Repository) RestoreNode(id int64) (bool, error) {
+	n, err := r.execCount(`UPDATE nodes SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	return n > 0, err
+}
+
+// PurgeDeletedNodes permanently removes nodes soft-deleted before the given time along with their history.
+func (r *Repository) PurgeDeletedNodes(before time.Time) (int64, error) {
+	return r.execCount(`DELETE FROM nodes WHERE deleted_at IS NOT NULL AND deleted_at < ?`, before)
+}
+
+// GetNodesSubset retrieves nodes for maintenance.
+// if onlyEmptyA2S is true, it returns only nodes where server_name is empty.
+// if appName is provided, it filters by application.
+func (r *Repository) GetNodesSubset(appName string, onlyEmptyA2S bool) ([]models.Node, error) {
+	query := `
+		SELECT ` + nodeColumns + `
+		FROM nodes
+		WHERE deleted_at IS NULL
+	`
+	var args []interface{}
+
+	if appName != "" {
+		query += " AND application = ?"
+		args = append(args, appName)
+	}
+
+	if onlyEmptyA2S {
+		query += " AND (server_name IS NULL OR server_name = '')"
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var nodes []models.Node
+	for rows.Next() {
+		var n models.Node
+		if err := scanNode(rows, &n); err != nil {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// ServerAddr addresses a game server, shared by the nodes of all applications it reports.
+type ServerAddr struct {
+	IP   string
+	Port int
+}
+
+// pollCondition matches nodes worth polling: of types queried on report or with A2S data from earlier queries.
+// Servers on IPv6 addresses are skipped, A2S queries are made over IPv4 only.
+const pollCondition = `deleted_at IS NULL AND (type IN ('steam', 'a2s') OR server_name <> '') AND ip NOT LIKE '%:%'`
+
+// GetStaleServers retrieves up to limit game servers not queried since before,
+// servers never queried first and then the longest not queried.
+func (r *Repository) GetStaleServers(before time.Time, limit int) ([]ServerAddr, error) {
+	rows, err := r.db.Query(`
+		SELECT ip, port FROM nodes
+		WHERE `+pollCondition+` AND (queried_at IS NULL OR queried_at < ?)
+		GROUP BY ip, port
+		ORDER BY MAX(CASE WHEN queried_at IS NULL THEN 0 ELSE 1 END), MIN(queried_at)
+		LIMIT ?
+	`, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var servers []ServerAddr
+	for rows.Next() {
+		var s ServerAddr
+		if err := rows.Scan(&s.IP, &s.Port); err != nil {
+			continue
+		}
+		servers = append(servers, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return servers, nil
+}
+
+// SaveServerPoll stores the outcome of a background A2S query of the game server at n.IP and n.Port,
+// queried at n.LastSeen, in all its nodes and appends their snapshots in a single transaction.
+// If online, the A2S data of n replaces the stored one and a scheduled retry is cancelled,
+// otherwise the nodes are marked offline with no players. Report counts and last seen times are kept.
+func (r *Repository) SaveServerPoll(n models.Node, online bool) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if online {
+		_, err = tx.Exec(`
+			UPDATE nodes SET
+				server_name = ?, map_name = ?, players = ?, max_players = ?,
+				game_version = ?, game_name = ?, server_os = ?, online = ?, queried_at = ?
+			WHERE ip = ? AND port = ? AND deleted_at IS NULL
+		`, n.ServerName, n.MapName, n.Players, n.MaxPlayers,
+			n.GameVersion, n.GameName, n.ServerOS, true, n.LastSeen,
+			n.IP, n.Port)
+		if err == nil {
+			_, err = tx.Exec(`DELETE FROM a2s_retries WHERE ip = ? AND port = ?`, n.IP, n.Port)
+		}
+	} else {
+		_, err = tx.Exec(`
+			UPDATE nodes SET players = 0, online = ?, queried_at = ?
+			WHERE ip = ? AND port = ? AND deleted_at IS NULL
+		`, false, n.LastSeen, n.IP, n.Port)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	// Read the nodes first and insert with VALUES, so the snapshot params are typed on PostgreSQL
+	nodes, err := pollNodes(tx, n.IP, n.Port)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, node := range nodes {
+		if _, err := tx.Exec(snapshotInsert,
+			node.Application, n.IP, n.Port,
+			n.LastSeen, node.Version, node.MapName, node.GameVersion, node.Players, node.MaxPlayers, online,
+		); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// pollNodes retrieves the snapshot fields of the live nodes of the game server at ip and port.
+func pollNodes(t *tx, ip string, port int) ([]models.Node, error) {
+	rows, err := t.Query(`
+		SELECT application, version, map_name, game_version, players, max_players
+		FROM nodes WHERE ip = ? AND port = ? AND deleted_at IS NULL
+	`, ip, port)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var nodes []models.Node
+	for rows.Next() {
+		var n models.Node
+		if err := rows.Scan(&n.Application, &n.Version, &n.MapName, &n.GameVersion, &n.Players, &n.MaxPlayers); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	return nodes, rows.Err()
+}
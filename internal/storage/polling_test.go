@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+func TestSaveServerPoll(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		seen := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+		for _, name := range []string{app, app + "-mod"} {
+			if err := r.UpsertNode(models.Node{
+				Application: name,
+				IP:          "192.0.2.3",
+				Port:        2302,
+				Version:     "1.0.0",
+				Type:        "steam",
+				ServerName:  "Test",
+				MapName:     "chernarusplus",
+				Players:     10,
+				MaxPlayers:  60,
+				FirstSeen:   seen,
+				LastSeen:    seen,
+			}); err != nil {
+				t.Fatalf("UpsertNode %s: %v", name, err)
+			}
+		}
+
+		polled := models.Node{
+			IP:         "192.0.2.3",
+			Port:       2302,
+			ServerName: "Test",
+			MapName:    "enoch",
+			Players:    20,
+			MaxPlayers: 60,
+			LastSeen:   seen.Add(5 * time.Minute),
+		}
+		if err := r.SaveServerPoll(polled, true); err != nil {
+			t.Fatalf("SaveServerPoll online: %v", err)
+		}
+
+		offline := polled
+		offline.LastSeen = seen.Add(10 * time.Minute)
+		if err := r.SaveServerPoll(offline, false); err != nil {
+			t.Fatalf("SaveServerPoll offline: %v", err)
+		}
+
+		for _, name := range []string{app, app + "-mod"} {
+			got, err := r.GetNode(name, "192.0.2.3", 2302)
+			if err != nil {
+				t.Fatalf("GetNode %s: %v", name, err)
+			}
+			if got.MapName != "enoch" || got.Players != 0 || got.Count != 1 || !got.LastSeen.Equal(seen) {
+				t.Errorf("node %s = %+v, want polled map, no players, count and last seen kept", name, got)
+			}
+
+			history, err := r.GetNodeHistory(name, "192.0.2.3", 2302, seen, seen.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("GetNodeHistory %s: %v", name, err)
+			}
+			if len(history) != 2 {
+				t.Fatalf("got %d snapshots of %s, want 2", len(history), name)
+			}
+			if !history[0].Online || history[0].Players != 20 || !history[0].Time.Equal(polled.LastSeen) {
+				t.Errorf("online snapshot of %s = %+v, want 20 players at %v", name, history[0], polled.LastSeen)
+			}
+			if history[1].Online || history[1].Players != 0 {
+				t.Errorf("offline snapshot of %s = %+v, want offline with no players", name, history[1])
+			}
+		}
+	})
+}
+
+func TestGetStaleServersIPv4(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		seen := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+		for _, ip := range []string{"192.0.2.30", "2001:db8::30"} {
+			if err := r.UpsertNode(models.Node{
+				Application: app, IP: ip, Port: 2302, Version: "1.0.0", Type: "steam", FirstSeen: seen, LastSeen: seen,
+			}); err != nil {
+				t.Fatalf("UpsertNode %s: %v", ip, err)
+			}
+		}
+
+		servers, err := r.GetStaleServers(seen, 1000)
+		if err != nil {
+			t.Fatalf("GetStaleServers: %v", err)
+		}
+
+		found := make(map[string]bool)
+		for _, s := range servers {
+			found[s.IP] = true
+		}
+		if !found["192.0.2.30"] || found["2001:db8::30"] {
+			t.Errorf("stale servers = %+v, want the IPv4 server only", servers)
+		}
+	})
+}
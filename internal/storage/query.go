@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// nodeSortColumns maps public sort keys to SQL columns used in ORDER BY.
+// Versions sort by semantic version precedence, invalid versions first as text.
+var nodeSortColumns = map[string][]string{
+	"id":          {"id"},
+	"last_seen":   {"last_seen"},
+	"first_seen":  {"first_seen"},
+	"count":       {"count"},
+	"players":     {"players"},
+	"server_name": {"server_name"},
+	"application": {"application"},
+	"map_name":    {"map_name"},
+	"version":     {"COALESCE(version_major, -1)", "version_minor", "version_patch", "version_pre", "version"},
+	"address":     {"ip", "port"},
+}
+
+// NodeFilter describes filtering, sorting and pagination of node listings.
+// Empty fields are not applied.
+type NodeFilter struct {
+	// Since restricts results to nodes seen at or after this time.
+	Since time.Time
+
+	Application string
+	Country     string
+	OS          string
+	Version     string
+	Map         string
+	Tag         string
+
+	// Verified restricts results to nodes whose last report was signed (true) or not (false).
+	Verified *bool
+
+	// VersionBounds restrict results to nodes with a semantic version matching all bounds.
+	VersionBounds []VersionBound
+
+	// Extra matches nodes whose custom fields equal the given values, all of them must match.
+	Extra map[string]string
+
+	// Search matches a case-insensitive substring of the server name or IP.
+	Search string
+
+	// Sort is one of the keys of nodeSortColumns, defaults to last_seen.
+	Sort string
+
+	// Cursor continues a listing after the last node of a page, as returned in NodePage.NextCursor.
+	// It is only valid with the same Sort and Desc.
+	Cursor string
+
+	// Limit is the maximum number of returned nodes, 0 means no limit.
+	Limit int
+
+	// Desc sorts in descending order.
+	Desc bool
+}
+
+// ValidSort reports whether key is a supported NodeFilter.Sort value.
+func ValidSort(key string) bool {
+	_, ok := nodeSortColumns[key]
+	return ok
+}
+
+// ListNodes retrieves a page of nodes matching the filter, sorted and continued from the cursor,
+// along with the total number of matching nodes before pagination.
+func (r *Repository) ListNodes(f NodeFilter) (*models.NodePage, error) {
+	where, args := f.where()
+
+	page := &models.NodePage{Limit: f.Limit}
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM nodes`+where, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	key, cols := f.sortColumns()
+	if f.Cursor != "" {
+		cond, vals, err := f.after(key, cols)
+		if err != nil {
+			return nil, err
+		}
+		where += " AND " + cond
+		args = append(args, vals...)
+	}
+
+	query := `
+		SELECT ` + nodeColumns + `, ` + strings.Join(cols, ", ") + `
+		FROM nodes` + where + f.orderBy()
+
+	// One more node tells whether a next page exists
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit+1)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var last []interface{}
+	for rows.Next() {
+		if f.Limit > 0 && len(page.Nodes) == f.Limit {
+			page.NextCursor, err = encodeCursor(nodeCursor{Sort: key, Desc: f.Desc}, last)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		var n models.Node
+		values := make([]interface{}, len(cols))
+		if err := scanNode(sortScanner{rowScanner: rows, values: values}, &n); err != nil {
+			continue
+		}
+		page.Nodes = append(page.Nodes, n)
+		last = values
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.attachTags(page.Nodes); err != nil {
+		return nil, err
+	}
+	if err := r.attachExtra(page.Nodes); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// where builds the WHERE clause and its arguments for the filter,
+// extra conditions without arguments are appended as is.
+// Soft-deleted nodes are always excluded.
+func (f NodeFilter) where(extra ...string) (string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	add := func(cond string, vals ...interface{}) {
+		conds = append(conds, cond)
+		args = append(args, vals...)
+	}
+
+	add("deleted_at IS NULL")
+
+	if !f.Since.IsZero() {
+		add("last_seen >= ?", f.Since)
+	}
+	if f.Application != "" {
+		add("application = ?", f.Application)
+	}
+	if f.Country != "" {
+		add("country_code = ?", f.Country)
+	}
+	if f.OS != "" {
+		add("server_os = ?", f.OS)
+	}
+	if f.Version != "" {
+		cond, vals := versionCondition(f.Version)
+		add(cond, vals...)
+	}
+	for _, b := range f.VersionBounds {
+		cond, vals := b.condition()
+		add(cond, vals...)
+	}
+	if f.Map != "" {
+		add("map_name = ?", f.Map)
+	}
+	if f.Tag != "" {
+		add("id IN (SELECT node_id FROM node_tags WHERE tag = ?)", f.Tag)
+	}
+	if f.Verified != nil {
+		add("verified = ?", *f.Verified)
+	}
+	if len(f.Extra) > 0 {
+		extraConds, extraArgs := extraConditions(f.Extra)
+		conds = append(conds, extraConds...)
+		args = append(args, extraArgs...)
+	}
+	if f.Search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(f.Search)) + "%"
+		add(`(LOWER(server_name) LIKE ? ESCAPE '\' OR ip LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+	conds = append(conds, extra...)
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// sortColumns returns the effective sort key and its ORDER BY columns, ending with id.
+func (f NodeFilter) sortColumns() (string, []string) {
+	key := f.Sort
+	cols, ok := nodeSortColumns[key]
+	if !ok {
+		key = "last_seen"
+		cols = nodeSortColumns[key]
+	}
+
+	return key, append(cols[:len(cols):len(cols)], "id")
+}
+
+// orderBy builds the ORDER BY clause, ties are broken by id for stable pagination.
+// NULL values sort last in both directions, as the default differs between SQLite and PostgreSQL.
+func (f NodeFilter) orderBy() string {
+	dir := "ASC"
+	if f.Desc {
+		dir = "DESC"
+	}
+
+	_, cols := f.sortColumns()
+	parts := make([]string, 0, len(cols))
+	for _, col := range cols {
+		parts = append(parts, col+" "+dir+" NULLS LAST")
+	}
+
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+// after builds the condition matching nodes sorted after the position of the filter cursor
+// in the order of orderBy, for the sort key and columns returned by sortColumns.
+func (f NodeFilter) after(key string, cols []string) (string, []interface{}, error) {
+	c, err := decodeCursor(f.Cursor)
+	if err != nil || c.Sort != key || c.Desc != f.Desc || len(c.Values) != len(cols) {
+		return "", nil, ErrInvalidCursor
+	}
+
+	op := ">"
+	if f.Desc {
+		op = "<"
+	}
+
+	// (c1 after v1) OR (c1 = v1 AND c2 after v2) OR ..., where nothing sorts after NULL
+	// and NULL sorts after any value
+	var (
+		alts  []string
+		equal []string
+		args  []interface{}
+		eqArg []interface{}
+	)
+	for i, col := range cols {
+		v := c.Values[i].value()
+		if v != nil {
+			alts = append(alts, "("+strings.Join(append(equal, "("+col+" "+op+" ? OR "+col+" IS NULL)"), " AND ")+")")
+			args = append(args, eqArg...)
+			args = append(args, v)
+
+			equal = append(equal, col+" = ?")
+			eqArg = append(eqArg, v)
+			continue
+		}
+		equal = append(equal, col+" IS NULL")
+	}
+	if len(alts) == 0 {
+		return "", nil, ErrInvalidCursor
+	}
+
+	return "(" + strings.Join(alts, " OR ") + ")", args, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+func TestListNodesCursor(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		seen := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+		versions := []string{"1.2.0", "custom", "1.10.0", "1.2.0", "1.2.0-rc.1", "custom", "0.9.0"}
+		for i, v := range versions {
+			n := models.Node{
+				Application: app,
+				IP:          "192.0.2.10",
+				Port:        2302 + i,
+				Version:     v,
+				Type:        "server",
+				Players:     byte(i % 3),
+				FirstSeen:   seen,
+				LastSeen:    seen.Add(time.Duration(i%2) * time.Minute),
+			}
+			if err := r.UpsertNode(n); err != nil {
+				t.Fatalf("UpsertNode: %v", err)
+			}
+		}
+
+		ids := func(nodes []models.Node) []int64 {
+			var ids []int64
+			for _, n := range nodes {
+				ids = append(ids, n.ID)
+			}
+			return ids
+		}
+
+		for _, sort := range []string{"version", "players", "last_seen", "address"} {
+			for _, desc := range []bool{false, true} {
+				f := NodeFilter{Application: app, Sort: sort, Desc: desc}
+				all, err := r.ListNodes(f)
+				if err != nil {
+					t.Fatalf("ListNodes: %v", err)
+				}
+
+				var paged []int64
+				f.Limit = 2
+				for pages := 0; ; pages++ {
+					if pages > len(versions) {
+						t.Fatalf("sort %s desc %v: pagination does not end", sort, desc)
+					}
+					page, err := r.ListNodes(f)
+					if err != nil {
+						t.Fatalf("ListNodes: %v", err)
+					}
+					if page.Total != int64(len(versions)) {
+						t.Errorf("total = %d, want %d", page.Total, len(versions))
+					}
+					paged = append(paged, ids(page.Nodes)...)
+					if page.NextCursor == "" {
+						break
+					}
+					f.Cursor = page.NextCursor
+				}
+
+				if want := ids(all.Nodes); !reflect.DeepEqual(paged, want) {
+					t.Errorf("sort %s desc %v: pages = %v, want %v", sort, desc, paged, want)
+				}
+			}
+		}
+
+		page, err := r.ListNodes(NodeFilter{Application: app, Sort: "version", Limit: 2})
+		if err != nil {
+			t.Fatalf("ListNodes: %v", err)
+		}
+		f := NodeFilter{Application: app, Sort: "players", Limit: 2, Cursor: page.NextCursor}
+		if _, err := r.ListNodes(f); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor of another sort error = %v, want %v", err, ErrInvalidCursor)
+		}
+		f.Cursor = "garbage"
+		if _, err := r.ListNodes(f); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("malformed cursor error = %v, want %v", err, ErrInvalidCursor)
+		}
+	})
+}
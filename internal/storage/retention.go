@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// History resolutions supported by GetNodeStats.
+const (
+	ResolutionHour = "hour"
+	ResolutionDay  = "day"
+)
+
+// RetentionPolicy defines how long each level of node history, daily node activity
+// and soft-deleted nodes are kept. A zero duration keeps the corresponding data forever.
+// Activity is kept for the monthly active window at least.
+type RetentionPolicy struct {
+	Raw      time.Duration
+	Hourly   time.Duration
+	Daily    time.Duration
+	Activity time.Duration
+	Deleted  time.Duration
+}
+
+// RetentionResult reports the work done by a single ApplyRetention pass.
+type RetentionResult struct {
+	HourlyBuckets   int64
+	DailyBuckets    int64
+	RawDeleted      int64
+	HourlyDeleted   int64
+	DailyDeleted    int64
+	ActivityDeleted int64
+	NodesPurged     int64
+}
+
+// rollupLevel describes an aggregate table and how raw snapshot timestamps are bucketed into it.
+type rollupLevel struct {
+	truncate func(time.Time) time.Time
+	name     string
+	period   time.Duration
+}
+
+var (
+	hourlyLevel = rollupLevel{
+		name:     "node_stats_hourly",
+		period:   time.Hour,
+		truncate: func(t time.Time) time.Time { return t.UTC().Truncate(time.Hour) },
+	}
+
+	dailyLevel = rollupLevel{
+		name:     "node_stats_daily",
+		period:   24 * time.Hour,
+		truncate: func(t time.Time) time.Time { return truncateDay(t.UTC()) },
+	}
+)
+
+// ApplyRetention rolls raw snapshots up into aggregates
+// and then deletes history and soft-deleted nodes older than the policy allows.
+// Raw snapshots are never deleted before they have been rolled up into both levels.
+func (r *Repository) ApplyRetention(p RetentionPolicy, now time.Time) (RetentionResult, error) {
+	var res RetentionResult
+
+	// Older raw snapshots may be pruned already, their buckets can not be recomputed
+	var keepFrom time.Time
+	if p.Raw > 0 {
+		keepFrom = now.Add(-p.Raw)
+	}
+
+	hourlyID, count, err := r.rollup(hourlyLevel, keepFrom)
+	if err != nil {
+		return res, fmt.Errorf("hourly rollup: %w", err)
+	}
+	res.HourlyBuckets = count
+
+	dailyID, count, err := r.rollup(dailyLevel, keepFrom)
+	if err != nil {
+		return res, fmt.Errorf("daily rollup: %w", err)
+	}
+	res.DailyBuckets = count
+
+	if p.Raw > 0 {
+		res.RawDeleted, err = r.execCount(
+			`DELETE FROM node_snapshots WHERE seen_at < ? AND id <= ?`, keepFrom, min(hourlyID, dailyID),
+		)
+		if err != nil {
+			return res, fmt.Errorf("prune raw snapshots: %w", err)
+		}
+	}
+
+	if p.Hourly > 0 {
+		res.HourlyDeleted, err = r.execCount(`DELETE FROM node_stats_hourly WHERE bucket < ?`, now.Add(-p.Hourly))
+		if err != nil {
+			return res, fmt.Errorf("prune hourly stats: %w", err)
+		}
+	}
+
+	if p.Daily > 0 {
+		res.DailyDeleted, err = r.execCount(`DELETE FROM node_stats_daily WHERE bucket < ?`, now.Add(-p.Daily))
+		if err != nil {
+			return res, fmt.Errorf("prune daily stats: %w", err)
+		}
+	}
+
+	if p.Activity > 0 {
+		cutoff := activityDay(now.Add(-p.Activity))
+		if month := activityDay(now).AddDate(0, 0, -(monthWindow - 1)); month.Before(cutoff) {
+			cutoff = month
+		}
+
+		res.ActivityDeleted, err = r.execCount(`DELETE FROM node_activity WHERE day < ?`, cutoff)
+		if err != nil {
+			return res, fmt.Errorf("prune node activity: %w", err)
+		}
+	}
+
+	if p.Deleted > 0 {
+		res.NodesPurged, err = r.PurgeDeletedNodes(now.Add(-p.Deleted))
+		if err != nil {
+			return res, fmt.Errorf("purge deleted nodes: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// GetNodeStats retrieves aggregated history of a specific node between from and to, oldest first.
+// resolution must be ResolutionHour or ResolutionDay.
+func (r *Repository) GetNodeStats(app, ip string, port int, resolution string, from, to time.Time) ([]models.NodeStat, error) {
+	var query string
+	switch resolution {
+	case ResolutionHour:
+		query = `
+		SELECT s.bucket, s.samples, s.online_samples, s.players_min, s.players_max, s.players_avg, s.versions
+		FROM node_stats_hourly s
+		JOIN nodes n ON n.id = s.node_id
+		WHERE n.application = ? AND n.ip = ? AND n.port = ?
+		  AND s.bucket >= ? AND s.bucket <= ?
+		ORDER BY s.bucket ASC
+		`
+	case ResolutionDay:
+		query = `
+		SELECT s.bucket, s.samples, s.online_samples, s.players_min, s.players_max, s.players_avg, s.versions
+		FROM node_stats_daily s
+		JOIN nodes n ON n.id = s.node_id
+		WHERE n.application = ? AND n.ip = ? AND n.port = ?
+		  AND s.bucket >= ? AND s.bucket <= ?
+		ORDER BY s.bucket ASC
+		`
+	default:
+		return nil, fmt.Errorf("unknown resolution %q", resolution)
+	}
+
+	rows, err := r.db.Query(query, app, ip, port, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var stats []models.NodeStat
+	for rows.Next() {
+		var s models.NodeStat
+		if err := rows.Scan(
+			&s.Bucket, &s.Samples, &s.OnlineSamples, &s.PlayersMin, &s.PlayersMax, &s.PlayersAvg, &s.Versions,
+		); err != nil {
+			continue
+		}
+		if s.Samples > 0 {
+			s.OnlineRatio = float64(s.OnlineSamples) / float64(s.Samples)
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// rollup aggregates raw snapshots stored since the last pass, in any bucket, and advances the position.
+// Buckets starting at or after keepFrom are recomputed from all their raw snapshots,
+// so snapshots written late, such as replayed from the spool, are included.
+// Raw snapshots of older buckets may be pruned already, late ones are merged into the stored aggregate,
+// counting distinct versions of the bucket at least. A zero keepFrom recomputes every bucket.
+// It returns the snapshot ID up to which all raw snapshots are aggregated at this level.
+func (r *Repository) rollup(level rollupLevel, keepFrom time.Time) (int64, int64, error) {
+	rolledID, tracked, err := r.rolledID(level.name)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var lastID int64
+	if err := r.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM node_snapshots`).Scan(&lastID); err != nil {
+		return 0, 0, err
+	}
+	if lastID <= rolledID {
+		return rolledID, 0, nil
+	}
+
+	recomputeFrom := level.truncate(keepFrom)
+	if recomputeFrom.Before(keepFrom) {
+		recomputeFrom = recomputeFrom.Add(level.period)
+	}
+
+	snapshotBucket := r.db.dialect.truncate("s.seen_at", level.period)
+	changedBucket := r.db.dialect.truncate("c.seen_at", level.period)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	res, err := tx.Exec(`
+		WITH changed AS (
+			SELECT DISTINCT c.node_id, `+changedBucket+` AS bucket
+			FROM node_snapshots c
+			WHERE c.id > ? AND c.id <= ? AND c.seen_at >= ?
+		)
+		INSERT INTO `+level.name+` (
+			node_id, bucket, samples, online_samples, players_min, players_max, players_avg, versions
+		)
+		SELECT s.node_id, `+snapshotBucket+`, COUNT(*), SUM(CASE WHEN s.online THEN 1 ELSE 0 END),
+		       MIN(s.players), MAX(s.players), AVG(s.players), COUNT(DISTINCT s.version)
+		FROM node_snapshots s
+		JOIN changed c ON c.node_id = s.node_id AND c.bucket = `+snapshotBucket+`
+		WHERE s.id <= ? AND s.seen_at >= (SELECT MIN(bucket) FROM changed)
+		GROUP BY s.node_id, `+snapshotBucket+`
+		ON CONFLICT(node_id, bucket) DO UPDATE SET
+			samples        = excluded.samples,
+			online_samples = excluded.online_samples,
+			players_min    = excluded.players_min,
+			players_max    = excluded.players_max,
+			players_avg    = excluded.players_avg,
+			versions       = excluded.versions
+	`, rolledID, lastID, recomputeFrom, lastID)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, 0, err
+	}
+	written, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, 0, err
+	}
+
+	// Older snapshots stored before positions were tracked were rolled up by time already
+	if tracked && !keepFrom.IsZero() {
+		t := level.name // aggregate table
+		res, err := tx.Exec(`
+			INSERT INTO `+t+` (
+				node_id, bucket, samples, online_samples, players_min, players_max, players_avg, versions
+			)
+			SELECT s.node_id, `+snapshotBucket+`, COUNT(*), SUM(CASE WHEN s.online THEN 1 ELSE 0 END),
+			       MIN(s.players), MAX(s.players), AVG(s.players), COUNT(DISTINCT s.version)
+			FROM node_snapshots s
+			WHERE s.id > ? AND s.id <= ? AND s.seen_at < ?
+			GROUP BY s.node_id, `+snapshotBucket+`
+			ON CONFLICT(node_id, bucket) DO UPDATE SET
+				players_avg    = (`+t+`.players_avg * `+t+`.samples + excluded.players_avg * excluded.samples)
+				               / (`+t+`.samples + excluded.samples),
+				samples        = `+t+`.samples + excluded.samples,
+				online_samples = `+t+`.online_samples + excluded.online_samples,
+				players_min    = CASE WHEN excluded.players_min < `+t+`.players_min
+				                 THEN excluded.players_min ELSE `+t+`.players_min END,
+				players_max    = CASE WHEN excluded.players_max > `+t+`.players_max
+				                 THEN excluded.players_max ELSE `+t+`.players_max END,
+				versions       = CASE WHEN excluded.versions > `+t+`.versions
+				                 THEN excluded.versions ELSE `+t+`.versions END
+		`, rolledID, lastID, recomputeFrom)
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, 0, err
+		}
+		merged, err := res.RowsAffected()
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, 0, err
+		}
+		written += merged
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO rollup_state (name, rolled_id) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET rolled_id = excluded.rolled_id
+	`, level.name, lastID); err != nil {
+		_ = tx.Rollback()
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return lastID, written, nil
+}
+
+// rolledID returns the ID of the last snapshot rolled up at an aggregate level, zero if never rolled.
+// tracked is false if the level was only rolled up by time, before snapshot IDs were tracked.
+func (r *Repository) rolledID(name string) (id int64, tracked bool, err error) {
+	var rolled sql.NullInt64
+	err = r.db.QueryRow(`SELECT rolled_id FROM rollup_state WHERE name = ?`, name).Scan(&rolled)
+	if err == sql.ErrNoRows {
+		return 0, true, nil
+	}
+
+	return rolled.Int64, rolled.Valid, err
+}
+
+// execCount executes a statement and returns the number of affected rows.
+func (r *Repository) execCount(query string, args ...interface{}) (int64, error) {
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// truncateDay returns midnight of the day t belongs to, in the location of t.
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+func TestApplyRetentionLateSnapshots(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		bucket := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+		n := models.Node{
+			Application: app,
+			IP:          "192.0.2.3",
+			Port:        2302,
+			Version:     "1.0.0",
+			Type:        "server",
+			FirstSeen:   bucket,
+			LastSeen:    bucket,
+		}
+		if err := r.UpsertNode(n); err != nil {
+			t.Fatalf("UpsertNode: %v", err)
+		}
+
+		snapshot := func(minute int, players byte, version string) {
+			t.Helper()
+			s := n
+			s.LastSeen = bucket.Add(time.Duration(minute) * time.Minute)
+			s.Players = players
+			s.Version = version
+			if err := r.AddSnapshot(s, true); err != nil {
+				t.Fatalf("AddSnapshot: %v", err)
+			}
+		}
+		hourly := func() models.NodeStat {
+			t.Helper()
+			stats, err := r.GetNodeStats(app, n.IP, n.Port, ResolutionHour, bucket, bucket)
+			if err != nil {
+				t.Fatalf("GetNodeStats: %v", err)
+			}
+			if len(stats) != 1 {
+				t.Fatalf("got %d hourly buckets, want 1", len(stats))
+			}
+			return stats[0]
+		}
+
+		policy := RetentionPolicy{Raw: 7 * 24 * time.Hour}
+		snapshot(10, 10, "1.0.0")
+		snapshot(20, 20, "1.0.0")
+		if _, err := r.ApplyRetention(policy, bucket.Add(2*time.Hour)); err != nil {
+			t.Fatalf("ApplyRetention: %v", err)
+		}
+		if s := hourly(); s.Samples != 2 || s.PlayersAvg != 15 {
+			t.Errorf("samples, avg = %d, %v, want 2, 15", s.Samples, s.PlayersAvg)
+		}
+
+		// A replayed snapshot of a rolled up hour recomputes it
+		snapshot(30, 30, "1.1.0")
+		if _, err := r.ApplyRetention(policy, bucket.Add(3*time.Hour)); err != nil {
+			t.Fatalf("ApplyRetention: %v", err)
+		}
+		if s := hourly(); s.Samples != 3 || s.PlayersMax != 30 || s.Versions != 2 {
+			t.Errorf("samples, max, versions = %d, %d, %d, want 3, 30, 2", s.Samples, s.PlayersMax, s.Versions)
+		}
+
+		// Raw snapshots of the hour are pruned, a later one is merged into the aggregate
+		late := bucket.Add(10 * 24 * time.Hour)
+		res, err := r.ApplyRetention(policy, late)
+		if err != nil {
+			t.Fatalf("ApplyRetention: %v", err)
+		}
+		if res.RawDeleted != 3 {
+			t.Errorf("raw deleted = %d, want 3", res.RawDeleted)
+		}
+
+		snapshot(40, 0, "1.0.0")
+		if _, err := r.ApplyRetention(policy, late); err != nil {
+			t.Fatalf("ApplyRetention: %v", err)
+		}
+		if s := hourly(); s.Samples != 4 || s.PlayersMin != 0 || s.PlayersAvg != 15 || s.Versions != 2 {
+			t.Errorf("samples, min, avg, versions = %d, %d, %v, %d, want 4, 0, 15, 2",
+				s.Samples, s.PlayersMin, s.PlayersAvg, s.Versions)
+		}
+
+		daily, err := r.GetNodeStats(app, n.IP, n.Port, ResolutionDay, bucket.Add(-10*time.Hour), bucket)
+		if err != nil {
+			t.Fatalf("GetNodeStats: %v", err)
+		}
+		if len(daily) != 1 || daily[0].Samples != 4 {
+			t.Errorf("daily = %+v, want one bucket of 4 samples", daily)
+		}
+	})
+}
+
+func TestApplyRetentionActivity(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+		n := models.Node{
+			Application: app,
+			IP:          "192.0.2.4",
+			Port:        2302,
+			Version:     "1.0.0",
+			Type:        "server",
+			FirstSeen:   now,
+			LastSeen:    now,
+		}
+		if err := r.UpsertNode(n); err != nil {
+			t.Fatalf("UpsertNode: %v", err)
+		}
+		for _, daysAgo := range []int{0, 29, 30, 45} {
+			day := activityDay(now.AddDate(0, 0, -daysAgo))
+			if _, err := r.db.Exec(activityInsert, day, app, app, n.IP, n.Port); err != nil {
+				t.Fatalf("insert activity: %v", err)
+			}
+		}
+
+		// A shorter retention still keeps the days of the monthly window
+		if _, err := r.ApplyRetention(RetentionPolicy{Activity: 24 * time.Hour}, now); err != nil {
+			t.Fatalf("ApplyRetention: %v", err)
+		}
+
+		activity, err := r.GetActivity(app, now.AddDate(0, 0, -60), now)
+		if err != nil {
+			t.Fatalf("GetActivity: %v", err)
+		}
+		if len(activity) != 1 {
+			t.Fatalf("got activity of %d applications, want 1", len(activity))
+		}
+		active := 0
+		for _, d := range activity[0].Days {
+			if d.DAU > 0 {
+				active++
+			}
+		}
+		if active != 2 {
+			t.Errorf("got %d active days, want 2", active)
+		}
+		if last := activity[0].Days[len(activity[0].Days)-1]; last.MAU != 1 {
+			t.Errorf("MAU of the last day = %d, want 1", last.MAU)
+		}
+	})
+}
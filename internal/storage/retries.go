@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// recordRetry schedules or cancels an A2S query retry of the server of a report within an existing transaction.
+// A server that answered cancels its retry, an already scheduled retry keeps its schedule.
+func recordRetry(t *tx, rep NodeReport) error {
+	n := rep.Node
+	if rep.Online {
+		_, err := t.Exec(`DELETE FROM a2s_retries WHERE ip = ? AND port = ?`, n.IP, n.Port)
+		return err
+	}
+
+	if rep.RetryAt.IsZero() {
+		return nil
+	}
+
+	_, err := t.Exec(`
+		INSERT INTO a2s_retries (ip, port, attempt, created_at, next_at) VALUES (?, ?, 0, ?, ?)
+		ON CONFLICT(ip, port) DO NOTHING
+	`, n.IP, n.Port, n.LastSeen, rep.RetryAt)
+
+	return err
+}
+
+// GetDueA2SRetries retrieves up to limit A2S query retries scheduled at or before now, the longest due first.
+func (r *Repository) GetDueA2SRetries(now time.Time, limit int) ([]models.A2SRetry, error) {
+	rows, err := r.db.Query(`
+		SELECT ip, port, attempt, created_at, next_at FROM a2s_retries
+		WHERE next_at <= ? ORDER BY next_at LIMIT ?
+	`, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var retries []models.A2SRetry
+	for rows.Next() {
+		var rt models.A2SRetry
+		if err := rows.Scan(&rt.IP, &rt.Port, &rt.Attempt, &rt.CreatedAt, &rt.NextAt); err != nil {
+			continue
+		}
+		retries = append(retries, rt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return retries, nil
+}
+
+// RescheduleA2SRetry sets the attempt and time of the next A2S query retry of a server.
+func (r *Repository) RescheduleA2SRetry(ip string, port, attempt int, next time.Time) error {
+	_, err := r.db.Exec(`UPDATE a2s_retries SET attempt = ?, next_at = ? WHERE ip = ? AND port = ?`,
+		attempt, next, ip, port)
+
+	return err
+}
+
+// DeleteA2SRetry removes the scheduled A2S query retry of a server.
+func (r *Repository) DeleteA2SRetry(ip string, port int) error {
+	_, err := r.db.Exec(`DELETE FROM a2s_retries WHERE ip = ? AND port = ?`, ip, port)
+	return err
+}
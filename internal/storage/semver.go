@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/semver"
+)
+
+// VersionBound restricts node listings to versions ordered before or after Version.
+type VersionBound struct {
+	// Op is one of "<", "<=", ">" or ">=".
+	Op string
+
+	Version semver.Version
+}
+
+// ValidVersionOp reports whether op is a supported VersionBound operator.
+func ValidVersionOp(op string) bool {
+	switch op {
+	case "<", "<=", ">", ">=":
+		return true
+	}
+
+	return false
+}
+
+// semverColumns are the sortable version components in precedence order.
+const semverColumns = "version_major, version_minor, version_patch, version_pre"
+
+// versionArgs returns the values of semverColumns for a reported version, all NULL if it is not a semantic version.
+func versionArgs(version string) []interface{} {
+	v, err := semver.Parse(version)
+	if err != nil {
+		return []interface{}{sql.NullInt64{}, sql.NullInt64{}, sql.NullInt64{}, sql.NullString{}}
+	}
+
+	return []interface{}{v.Major, v.Minor, v.Patch, v.PreKey()}
+}
+
+// condition returns the filter condition of the bound and its arguments.
+// Nodes without a semantic version never match.
+func (b VersionBound) condition() (string, []interface{}) {
+	cond := fmt.Sprintf("version_major IS NOT NULL AND (%s) %s (?, ?, ?, ?)", semverColumns, b.Op)
+	return cond, []interface{}{b.Version.Major, b.Version.Minor, b.Version.Patch, b.Version.PreKey()}
+}
+
+// versionCondition returns the condition matching nodes that report version and its arguments.
+// A semantic version also matches the legacy spellings stored before versions were normalized,
+// such as "v1.2", by its components and build metadata.
+func versionCondition(version string) (string, []interface{}) {
+	v, err := semver.Parse(version)
+	if err != nil {
+		return "version = ?", []interface{}{version}
+	}
+
+	// Build identifiers are alphanumerics and hyphens, they need no LIKE escaping
+	build, buildArg := "version NOT LIKE ?", "%+%"
+	if v.Build != "" {
+		build, buildArg = "version LIKE ?", "%+"+v.Build
+	}
+
+	cond := "(version = ? OR (version_major = ? AND version_minor = ? AND version_patch = ? AND version_pre = ? AND " + build + "))"
+	return cond, []interface{}{v.String(), v.Major, v.Minor, v.Patch, v.PreKey(), buildArg}
+}
+
+// mergeVersionCounts merges the counts of spellings of the same semantic version into its canonical form,
+// most common first.
+func mergeVersionCounts(counts []models.GroupCount) []models.GroupCount {
+	merged := make([]models.GroupCount, 0, len(counts))
+	index := make(map[string]int, len(counts))
+	for _, c := range counts {
+		c.Name, _ = semver.Normalize(c.Name)
+		if i, ok := index[c.Name]; ok {
+			merged[i].Count += c.Count
+			continue
+		}
+		index[c.Name] = len(merged)
+		merged = append(merged, c)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Count != merged[j].Count {
+			return merged[i].Count > merged[j].Count
+		}
+		return merged[i].Name < merged[j].Name
+	})
+
+	return merged
+}
+
+// backfillVersions fills semantic version components of nodes stored before they were tracked,
+// the reported versions are kept as stored. Nodes with an invalid version are checked again on every start.
+func backfillVersions(db *db) error {
+	rows, err := db.Query(`SELECT id, version FROM nodes WHERE version_major IS NULL AND version <> ''`)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		version string
+		id      int64
+	}
+	var nodes []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.version); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		nodes = append(nodes, p)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodes {
+		if _, err := semver.Parse(n.version); err != nil {
+			continue
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE nodes SET version_major = ?, version_minor = ?, version_patch = ?, version_pre = ?
+			WHERE id = ?
+		`, append(versionArgs(n.version), n.id)...); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
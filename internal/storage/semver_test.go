@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+func TestBackfillVersions(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		seen := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+		versions := map[string]string{
+			"192.0.2.10": "v1.2-rc.1",
+			"192.0.2.11": "dev build",
+		}
+		for ip, version := range versions {
+			if err := r.UpsertNode(models.Node{
+				Application: app, IP: ip, Port: 2302, Version: version, FirstSeen: seen, LastSeen: seen,
+			}); err != nil {
+				t.Fatalf("UpsertNode: %v", err)
+			}
+		}
+
+		// Simulate nodes stored before semantic versions were tracked
+		if _, err := r.db.Exec(`
+			UPDATE nodes SET version_major = NULL, version_minor = NULL, version_patch = NULL, version_pre = NULL
+			WHERE application = ?
+		`, app); err != nil {
+			t.Fatalf("reset versions: %v", err)
+		}
+
+		if err := backfillVersions(r.db); err != nil {
+			t.Fatalf("backfillVersions: %v", err)
+		}
+
+		for ip, version := range versions {
+			var (
+				stored string
+				major  sql.NullInt64
+				minor  sql.NullInt64
+				patch  sql.NullInt64
+				pre    sql.NullString
+			)
+			if err := r.db.QueryRow(`
+				SELECT version, `+semverColumns+` FROM nodes WHERE application = ? AND ip = ?
+			`, app, ip).Scan(&stored, &major, &minor, &patch, &pre); err != nil {
+				t.Fatalf("select %s: %v", ip, err)
+			}
+
+			if stored != version {
+				t.Errorf("version of %s = %q, want it kept as %q", ip, stored, version)
+			}
+
+			valid := version != "dev build"
+			if major.Valid != valid {
+				t.Errorf("version_major of %s valid = %v, want %v", ip, major.Valid, valid)
+			}
+			if valid && (major.Int64 != 1 || minor.Int64 != 2 || patch.Int64 != 0 || pre.String != "1rc 00000000000000000001") {
+				t.Errorf("components of %s = %v %v %v %q, want 1 2 0 rc.1", ip, major, minor, patch, pre.String)
+			}
+		}
+	})
+}
+
+func TestLegacyVersionSpellings(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		seen := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+		node := func(ip, version string) models.Node {
+			return models.Node{
+				Application: app, IP: ip, Port: 2302, Version: version, Type: "server", FirstSeen: seen, LastSeen: seen,
+			}
+		}
+
+		// Versions stored before they were normalized on ingest
+		for ip, version := range map[string]string{
+			"192.0.2.20": "v1.2",
+			"192.0.2.21": "1.2.0",
+			"192.0.2.22": "1.2.0+b1",
+			"192.0.2.23": "dev build",
+		} {
+			if err := r.UpsertNode(node(ip, version)); err != nil {
+				t.Fatalf("UpsertNode: %v", err)
+			}
+		}
+
+		// The normalized spelling of the stored version is not a version change
+		if err := r.UpsertNode(node("192.0.2.20", "1.2.0")); err != nil {
+			t.Fatalf("UpsertNode: %v", err)
+		}
+		events, err := r.GetVersionEvents(app, seen.Add(-time.Hour), seen.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("GetVersionEvents: %v", err)
+		}
+		if len(events) != 4 {
+			t.Errorf("got %d version events, want 4", len(events))
+		}
+
+		if _, err := r.db.Exec(`UPDATE nodes SET version = 'v1.2' WHERE application = ? AND ip = '192.0.2.20'`, app); err != nil {
+			t.Fatalf("restore legacy version: %v", err)
+		}
+
+		page, err := r.ListNodes(NodeFilter{Application: app, Version: "1.2.0"})
+		if err != nil {
+			t.Fatalf("ListNodes: %v", err)
+		}
+		if page.Total != 2 {
+			t.Errorf("nodes of version 1.2.0 = %d, want 2", page.Total)
+		}
+
+		page, err = r.ListNodes(NodeFilter{Application: app, Version: "1.2.0+b1"})
+		if err != nil {
+			t.Fatalf("ListNodes: %v", err)
+		}
+		if page.Total != 1 {
+			t.Errorf("nodes of version 1.2.0+b1 = %d, want 1", page.Total)
+		}
+
+		summary, err := r.GetSummary(NodeFilter{Application: app}, 0, ResolutionDay, nil)
+		if err != nil {
+			t.Fatalf("GetSummary: %v", err)
+		}
+		want := []models.GroupCount{{Name: "1.2.0", Count: 2}, {Name: "1.2.0+b1", Count: 1}, {Name: "dev build", Count: 1}}
+		if !reflect.DeepEqual(summary.Versions, want) {
+			t.Errorf("versions = %+v, want %+v", summary.Versions, want)
+		}
+	})
+}
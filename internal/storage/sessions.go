@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/semver"
+)
+
+// sessionNode resolves the node of a session by (Application, IP, Port).
+const sessionNode = `(SELECT id FROM nodes WHERE application = ? AND ip = ? AND port = ?)`
+
+// recordSession applies the lifecycle event of a report to the sessions of its node
+// within an existing transaction. Reports without an event are ignored.
+func recordSession(t *tx, n models.Node, event string) error {
+	switch event {
+	case models.EventStart:
+		// An open session followed by a new start ended without a stop
+		if _, err := t.Exec(`
+			UPDATE node_sessions SET state = ?, ended_at = last_heartbeat
+			WHERE node_id = `+sessionNode+` AND state = ?
+		`, models.SessionUnclean, n.Application, n.IP, n.Port, models.SessionOpen); err != nil {
+			return err
+		}
+
+		return openSession(t, n)
+
+	case models.EventHeartbeat:
+		res, err := t.Exec(`
+			UPDATE node_sessions SET last_heartbeat = ?, heartbeats = heartbeats + 1, version = ?
+			WHERE node_id = `+sessionNode+` AND state = ?
+		`, n.LastSeen, n.Version, n.Application, n.IP, n.Port, models.SessionOpen)
+		if err != nil {
+			return err
+		}
+
+		// The start was lost or the session timed out, the heartbeat opens a new one
+		if affected, err := res.RowsAffected(); err != nil || affected > 0 {
+			return err
+		}
+
+		return openSession(t, n)
+
+	case models.EventStop:
+		// A stop without an open session has nothing to close
+		_, err := t.Exec(`
+			UPDATE node_sessions SET state = ?, ended_at = ?, last_heartbeat = ?, version = ?
+			WHERE node_id = `+sessionNode+` AND state = ?
+		`, models.SessionClean, n.LastSeen, n.LastSeen, n.Version, n.Application, n.IP, n.Port, models.SessionOpen)
+
+		return err
+	}
+
+	return nil
+}
+
+// openSession starts a new session of a node at its report time.
+func openSession(t *tx, n models.Node) error {
+	_, err := t.Exec(`
+		INSERT INTO node_sessions (node_id, application, version, state, started_at, last_heartbeat)
+		VALUES (`+sessionNode+`, ?, ?, ?, ?, ?)
+	`, n.Application, n.IP, n.Port, n.Application, n.Version, models.SessionOpen, n.LastSeen, n.LastSeen)
+
+	return err
+}
+
+// CloseStaleSessions closes open sessions without a heartbeat since before as unclean,
+// ending them at their last heartbeat, and returns the number of closed sessions.
+func (r *Repository) CloseStaleSessions(before time.Time) (int64, error) {
+	res, err := r.db.Exec(`
+		UPDATE node_sessions SET state = ?, ended_at = last_heartbeat
+		WHERE state = ? AND last_heartbeat < ?
+	`, models.SessionUnclean, models.SessionOpen, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// GetNodeSessions retrieves sessions of a node started between from and to, most recent first.
+func (r *Repository) GetNodeSessions(nodeID int64, from, to time.Time) ([]models.Session, error) {
+	return r.sessions(`WHERE node_id = ? AND started_at >= ? AND started_at <= ? ORDER BY started_at DESC`,
+		nodeID, from, to)
+}
+
+// GetVersionSessions aggregates sessions started between from and to per application and version,
+// ordered by application and semantic version. If appName is provided (not empty),
+// it restricts results to that application.
+func (r *Repository) GetVersionSessions(appName string, from, to time.Time) ([]models.VersionSessions, error) {
+	where := `WHERE started_at >= ? AND started_at <= ?`
+	args := []interface{}{from, to}
+	if appName != "" {
+		where += " AND application = ?"
+		args = append(args, appName)
+	}
+
+	sessions, err := r.sessions(where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	type versionKey struct{ app, version string }
+	groups := make(map[versionKey]*models.VersionSessions)
+	for _, s := range sessions {
+		k := versionKey{s.Application, s.Version}
+		g, ok := groups[k]
+		if !ok {
+			g = &models.VersionSessions{Application: s.Application, Version: s.Version}
+			groups[k] = g
+		}
+
+		g.Sessions++
+		g.TotalUptime += s.Duration
+		switch s.State {
+		case models.SessionOpen:
+			g.Open++
+			continue
+		case models.SessionClean:
+			g.Clean++
+		default:
+			g.Unclean++
+		}
+		g.AvgUptime += s.Duration
+		g.MaxUptime = max(g.MaxUptime, s.Duration)
+	}
+
+	result := make([]models.VersionSessions, 0, len(groups))
+	for _, g := range groups {
+		if closed := g.Clean + g.Unclean; closed > 0 {
+			g.AvgUptime /= closed
+			g.CrashRate = float64(g.Unclean) / float64(closed)
+		}
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Application != result[j].Application {
+			return result[i].Application < result[j].Application
+		}
+		return compareVersions(result[i].Version, result[j].Version) < 0
+	})
+
+	return result, nil
+}
+
+// sessions retrieves sessions selected by a WHERE (and ORDER BY) clause.
+func (r *Repository) sessions(where string, args ...interface{}) ([]models.Session, error) {
+	rows, err := r.db.Query(`
+		SELECT id, node_id, application, version, state, started_at, last_heartbeat, ended_at, heartbeats
+		FROM node_sessions
+	`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var (
+			s       models.Session
+			endedAt sql.NullTime
+		)
+		if err := rows.Scan(
+			&s.ID, &s.NodeID, &s.Application, &s.Version, &s.State,
+			&s.StartedAt, &s.LastHeartbeat, &endedAt, &s.Heartbeats,
+		); err != nil {
+			continue
+		}
+
+		end := s.LastHeartbeat
+		if endedAt.Valid {
+			s.EndedAt = &endedAt.Time
+			end = endedAt.Time
+		}
+		s.Duration = int64(end.Sub(s.StartedAt) / time.Second)
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// compareVersions orders semantic versions by precedence, other versions before them by text.
+func compareVersions(a, b string) int {
+	va, errA := semver.Parse(a)
+	vb, errB := semver.Parse(b)
+
+	switch {
+	case errA == nil && errB == nil:
+		return semver.Compare(va, vb)
+	case errA == nil:
+		return 1
+	case errB == nil:
+		return -1
+	}
+
+	return strings.Compare(a, b)
+}
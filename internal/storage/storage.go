@@ -0,0 +1,129 @@
+// Package storage handles database connections, schema migrations, and data operations
+// using SQLite or PostgreSQL.
+package storage
+
+import (
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// Store is the persistence backend used by the server, maintenance tasks and data generation.
+type Store interface {
+	// Close closes the underlying database connection.
+	Close() error
+
+	// UpsertNode inserts a new node or updates an existing one based on the Application, IP, and Port constraint.
+	UpsertNode(n models.Node) error
+	// GetNodes retrieves all nodes, sorted by the last seen timestamp in descending order.
+	GetNodes() ([]models.Node, error)
+	// GetNode retrieves a specific node by its unique identifier (Application, IP, Port), nil if not found.
+	GetNode(app, ip string, port int) (*models.Node, error)
+	// GetNodeByID retrieves a specific node by its database ID, nil if not found.
+	GetNodeByID(id int64) (*models.Node, error)
+	// DeleteNode soft-deletes a specific node identified by app, ip, and port.
+	DeleteNode(app, ip string, port int) error
+	// DeleteNodeByID soft-deletes a specific node by its database ID.
+	DeleteNodeByID(id int64) error
+	// GetDeletedNodes retrieves soft-deleted nodes, most recently deleted first.
+	GetDeletedNodes() ([]models.Node, error)
+	// RestoreNode clears the tombstone of a soft-deleted node, false if no deleted node has the ID.
+	RestoreNode(id int64) (bool, error)
+	// PurgeDeletedNodes permanently removes nodes soft-deleted before the given time.
+	PurgeDeletedNodes(before time.Time) (int64, error)
+	// DeleteEmptyNodes soft-deletes nodes with empty A2S data, optionally restricted to an application.
+	DeleteEmptyNodes(appName string) (int64, error)
+	// GetNodesSubset retrieves nodes for maintenance, optionally filtered by application and empty A2S data.
+	GetNodesSubset(appName string, onlyEmptyA2S bool) ([]models.Node, error)
+	// ListNodes retrieves a page of nodes matching the filter, sorted, with the total match count.
+	ListNodes(f NodeFilter) (*models.NodePage, error)
+	// SaveReports upserts nodes, appends their snapshots, marks them active,
+	// applies their lifecycle events, extends their uptime and schedules A2S query retries in a single transaction.
+	SaveReports(reports []NodeReport) error
+	// GetSummary aggregates nodes matching the filter into totals, grouped counts, a timeline and top servers,
+	// plus counts grouped by each of the given custom field keys.
+	GetSummary(f NodeFilter, top int, resolution string, extraGroups []string) (*models.Summary, error)
+
+	// GetAnnotation retrieves notes, owner contact and tags of a node, empty if it has none.
+	GetAnnotation(nodeID int64) (*models.NodeAnnotation, error)
+	// SaveAnnotation replaces notes, owner contact and tags of a node.
+	SaveAnnotation(a models.NodeAnnotation) error
+	// DeleteAnnotation removes notes, owner contact and tags of a node.
+	DeleteAnnotation(nodeID int64) error
+	// GetTags retrieves all tags in use with the number of tagged nodes.
+	GetTags() ([]models.GroupCount, error)
+
+	// AddSnapshot appends the current state of an already upserted node to its history.
+	AddSnapshot(n models.Node, online bool) error
+	// GetNodeHistory retrieves raw snapshots of a specific node between from and to.
+	GetNodeHistory(app, ip string, port int, from, to time.Time) ([]models.NodeSnapshot, error)
+	// GetNodeStats retrieves hourly or daily aggregated history of a specific node between from and to.
+	GetNodeStats(app, ip string, port int, resolution string, from, to time.Time) ([]models.NodeStat, error)
+	// ApplyRetention rolls history up into aggregates and prunes data older than the policy allows.
+	ApplyRetention(p RetentionPolicy, now time.Time) (RetentionResult, error)
+
+	// MigrationStatus lists embedded and applied schema migrations without applying anything.
+	MigrationStatus() ([]MigrationState, error)
+	// MigrateTo applies or rolls back schema migrations until version is the latest applied one.
+	MigrateTo(version string, dryRun bool) error
+
+	// ExportNodes calls fn for every not deleted node with its tags and custom fields, ordered by ID.
+	ExportNodes(fn func(models.Node) error) error
+	// ImportNodes merges nodes into the database by their unique identifier (Application, IP, Port).
+	ImportNodes(nodes []models.Node) (ImportResult, error)
+
+	// Backup writes a consistent snapshot of the database into a new file at path.
+	Backup(path string) error
+
+	// GetActivity computes daily, weekly and monthly active nodes for every day between from and to,
+	// per application, optionally for a single application.
+	GetActivity(appName string, from, to time.Time) ([]models.AppActivity, error)
+
+	// GetVersionEvents retrieves version changes recorded between from and to, optionally for an application.
+	GetVersionEvents(appName string, from, to time.Time) ([]models.VersionEvent, error)
+
+	// CloseStaleSessions closes open sessions without a heartbeat since before as unclean.
+	CloseStaleSessions(before time.Time) (int64, error)
+	// GetNodeSessions retrieves sessions of a node started between from and to, most recent first.
+	GetNodeSessions(nodeID int64, from, to time.Time) ([]models.Session, error)
+	// GetVersionSessions aggregates sessions started between from and to per application and version,
+	// optionally for a single application.
+	GetVersionSessions(appName string, from, to time.Time) ([]models.VersionSessions, error)
+
+	// GetNodeUptime computes availability of a node between from and to from the gaps between its reports.
+	GetNodeUptime(nodeID int64, from, to time.Time) (*models.Uptime, error)
+	// GetVersionUptime computes availability of tracked nodes between from and to per application and version,
+	// optionally for a single application.
+	GetVersionUptime(appName string, from, to time.Time) ([]models.VersionUptime, error)
+
+	// GetDueA2SRetries retrieves up to limit A2S query retries scheduled at or before now, the longest due first.
+	GetDueA2SRetries(now time.Time, limit int) ([]models.A2SRetry, error)
+	// RescheduleA2SRetry sets the attempt and time of the next A2S query retry of a server.
+	RescheduleA2SRetry(ip string, port, attempt int, next time.Time) error
+	// DeleteA2SRetry removes the scheduled A2S query retry of a server.
+	DeleteA2SRetry(ip string, port int) error
+
+	// GetStaleServers retrieves up to limit game servers not queried since before, the stalest first.
+	GetStaleServers(before time.Time, limit int) ([]ServerAddr, error)
+	// SaveServerPoll stores the outcome of a background A2S query of a game server in all its nodes.
+	SaveServerPoll(n models.Node, online bool) error
+}
+
+// Repository implements Store on top of database/sql.
+// The same queries serve both SQLite and PostgreSQL, placeholders are adapted by the dialect.
+type Repository struct {
+	db *db
+}
+
+// compile-time check that Repository satisfies Store
+var _ Store = (*Repository)(nil)
+
+// Close closes the underlying database connection.
+func (r *Repository) Close() error {
+	return r.db.Close()
+}
+
+// Dialect returns the name of the database backend ("sqlite" or "postgres").
+func (r *Repository) Dialect() string {
+	return r.db.dialect.name
+}
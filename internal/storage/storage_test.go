@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// testPostgresDSN names the environment variable with a PostgreSQL DSN for the storage tests,
+// PostgreSQL is skipped when it is not set. The tests use unique application names,
+// so an existing database can be reused.
+const testPostgresDSN = "ZENIT_TEST_POSTGRES_DSN"
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// testStores runs fn against a temporary SQLite database and against PostgreSQL if configured.
+func testStores(t *testing.T, fn func(t *testing.T, r *Repository, app string)) {
+	t.Helper()
+
+	app := fmt.Sprintf("test-%d", time.Now().UnixNano())
+
+	t.Run("sqlite", func(t *testing.T) {
+		r, err := NewSQLite(filepath.Join(t.TempDir(), "zenit.db"))
+		if err != nil {
+			t.Fatalf("NewSQLite: %v", err)
+		}
+		t.Cleanup(func() { _ = r.Close() })
+
+		fn(t, r, app)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv(testPostgresDSN)
+		if dsn == "" {
+			t.Skip(testPostgresDSN + " is not set")
+		}
+
+		r, err := NewPostgres(dsn)
+		if err != nil {
+			t.Fatalf("NewPostgres: %v", err)
+		}
+		t.Cleanup(func() { _ = r.Close() })
+
+		fn(t, r, app)
+	})
+}
+
+func TestUpsertNode(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		seen := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+		n := models.Node{
+			Application: app,
+			IP:          "192.0.2.1",
+			Port:        2302,
+			Version:     "1.2.0",
+			Type:        "server",
+			FirstSeen:   seen,
+			LastSeen:    seen,
+		}
+		if err := r.UpsertNode(n); err != nil {
+			t.Fatalf("first UpsertNode: %v", err)
+		}
+
+		n.Version = "1.3.0"
+		n.LastSeen = seen.Add(time.Minute)
+		n.ServerName = "Test"
+		n.Players = 5
+		n.MaxPlayers = 60
+		if err := r.UpsertNode(n); err != nil {
+			t.Fatalf("second UpsertNode: %v", err)
+		}
+
+		got, err := r.GetNode(app, n.IP, n.Port)
+		if err != nil {
+			t.Fatalf("GetNode: %v", err)
+		}
+		if got.Count != 2 {
+			t.Errorf("count = %d, want 2", got.Count)
+		}
+		if got.Version != "1.3.0" || got.ServerName != "Test" || got.Players != 5 {
+			t.Errorf("node = %+v, want updated version and A2S fields", got)
+		}
+		if !got.FirstSeen.Equal(seen) || !got.LastSeen.Equal(n.LastSeen) {
+			t.Errorf("first/last seen = %v/%v, want %v/%v", got.FirstSeen, got.LastSeen, seen, n.LastSeen)
+		}
+
+		events, err := r.GetVersionEvents(app, seen.Add(-time.Hour), seen.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("GetVersionEvents: %v", err)
+		}
+		if len(events) != 2 {
+			t.Errorf("got %d version events, want 2", len(events))
+		}
+
+		// Snapshots in a non-UTC zone must still match UTC range filters
+		zone := time.FixedZone("UTC+2", 2*60*60)
+		n.LastSeen = n.LastSeen.In(zone)
+		if err := r.AddSnapshot(n, true); err != nil {
+			t.Fatalf("AddSnapshot: %v", err)
+		}
+
+		history, err := r.GetNodeHistory(app, n.IP, n.Port, seen, seen.Add(2*time.Minute))
+		if err != nil {
+			t.Fatalf("GetNodeHistory: %v", err)
+		}
+		if len(history) != 1 || !history[0].Time.Equal(n.LastSeen) {
+			t.Errorf("history = %+v, want one snapshot at %v", history, n.LastSeen)
+		}
+	})
+}
+
+func TestSaveReports(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		seen := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+		var reports []NodeReport
+		for i := 0; i < 3; i++ {
+			at := seen.Add(time.Duration(i) * time.Minute)
+			reports = append(reports, NodeReport{
+				Node: models.Node{
+					Application: app,
+					IP:          "192.0.2.2",
+					Port:        2302,
+					Version:     "1.0.0",
+					Type:        "server",
+					ServerName:  "Test",
+					Players:     byte(i),
+					MaxPlayers:  60,
+					FirstSeen:   at,
+					LastSeen:    at,
+				},
+				Online: true,
+			})
+		}
+		if err := r.SaveReports(reports); err != nil {
+			t.Fatalf("SaveReports: %v", err)
+		}
+
+		got, err := r.GetNode(app, "192.0.2.2", 2302)
+		if err != nil {
+			t.Fatalf("GetNode: %v", err)
+		}
+		if got.Count != 3 || got.Players != 2 {
+			t.Errorf("count, players = %d, %d, want 3, 2", got.Count, got.Players)
+		}
+
+		history, err := r.GetNodeHistory(app, "192.0.2.2", 2302, seen, seen.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("GetNodeHistory: %v", err)
+		}
+		if len(history) != 3 {
+			t.Fatalf("got %d snapshots, want 3", len(history))
+		}
+		if !history[2].Online || history[2].Players != 2 {
+			t.Errorf("last snapshot = %+v, want online with 2 players", history[2])
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// onlineCondition matches nodes whose last A2S query succeeded.
+const onlineCondition = "online"
+
+// GetSummary aggregates nodes matching the filter into totals, grouped counts,
+// a last seen timeline bucketed by resolution (ResolutionHour or ResolutionDay)
+// and the top servers by report count. Nodes are also counted per value of every custom field key
+// in extraGroups. Sorting and pagination of the filter are ignored.
+func (r *Repository) GetSummary(f NodeFilter, top int, resolution string, extraGroups []string) (*models.Summary, error) {
+	var period time.Duration
+	switch resolution {
+	case ResolutionHour:
+		period = hourlyLevel.period
+	case ResolutionDay:
+		period = dailyLevel.period
+	default:
+		return nil, fmt.Errorf("unknown resolution %q", resolution)
+	}
+
+	s := &models.Summary{}
+	where, args := f.where()
+
+	if err := r.db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT ip),
+		       COALESCE(SUM(CASE WHEN `+onlineCondition+` THEN 1 ELSE 0 END), 0)
+		FROM nodes`+where, args...,
+	).Scan(&s.Total, &s.UniqueHosts, &s.Online); err != nil {
+		return nil, fmt.Errorf("totals: %w", err)
+	}
+	s.Offline = s.Total - s.Online
+
+	// The same game server may report several applications, count it and its players once
+	onlineWhere, onlineArgs := f.where(onlineCondition)
+	if err := r.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(players), 0)
+		FROM (
+			SELECT ip, port, MAX(players) AS players
+			FROM nodes`+onlineWhere+`
+			GROUP BY ip, port
+		) servers`, onlineArgs...,
+	).Scan(&s.UniqueServers, &s.Players); err != nil {
+		return nil, fmt.Errorf("servers: %w", err)
+	}
+
+	groups := []struct {
+		dst    *[]models.GroupCount
+		column string
+	}{
+		{&s.Applications, "application"},
+		{&s.Countries, "country_code"},
+		{&s.OS, "server_os"},
+		{&s.Versions, "version"},
+		{&s.Maps, "map_name"},
+	}
+	for _, g := range groups {
+		counts, err := r.groupCounts(g.column, where, args)
+		if err != nil {
+			return nil, fmt.Errorf("group by %s: %w", g.column, err)
+		}
+		*g.dst = counts
+	}
+	s.Versions = mergeVersionCounts(s.Versions)
+
+	if len(extraGroups) > 0 {
+		s.Extra = make(map[string][]models.GroupCount, len(extraGroups))
+		for _, key := range extraGroups {
+			counts, err := r.extraGroupCounts(key, where, args)
+			if err != nil {
+				return nil, fmt.Errorf("group by extra %s: %w", key, err)
+			}
+			s.Extra[key] = counts
+		}
+	}
+
+	timeline, err := r.timeline(where, args, period)
+	if err != nil {
+		return nil, fmt.Errorf("timeline: %w", err)
+	}
+	s.Timeline = timeline
+
+	if top > 0 {
+		f.Sort, f.Desc, f.Limit, f.Cursor = "count", true, top, ""
+		page, err := r.ListNodes(f)
+		if err != nil {
+			return nil, fmt.Errorf("top servers: %w", err)
+		}
+		s.TopServers = page.Nodes
+	}
+	if s.TopServers == nil {
+		s.TopServers = []models.Node{}
+	}
+
+	return s, nil
+}
+
+// groupCounts counts nodes per distinct value of column, most common first.
+// column must be a trusted column name, it is not escaped.
+func (r *Repository) groupCounts(column, where string, args []interface{}) ([]models.GroupCount, error) {
+	rows, err := r.db.Query(`
+		SELECT COALESCE(`+column+`, ''), COUNT(*)
+		FROM nodes`+where+`
+		GROUP BY COALESCE(`+column+`, '')
+		ORDER BY COUNT(*) DESC, COALESCE(`+column+`, '') ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := []models.GroupCount{}
+	for rows.Next() {
+		var c models.GroupCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// timeline counts nodes by the UTC hour or day (period) they were last seen, oldest first.
+func (r *Repository) timeline(where string, args []interface{}, period time.Duration) ([]models.TimeCount, error) {
+	bucket := r.db.dialect.truncate("last_seen", period)
+	rows, err := r.db.Query(`
+		SELECT `+bucket+`, COUNT(*)
+		FROM nodes`+where+`
+		GROUP BY `+bucket+`
+		ORDER BY `+bucket, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	timeline := []models.TimeCount{}
+	for rows.Next() {
+		var c models.TimeCount
+		if err := rows.Scan(timeScanner{&c.Bucket}, &c.Count); err != nil {
+			return nil, err
+		}
+		timeline = append(timeline, c)
+	}
+
+	return timeline, rows.Err()
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+func TestSummaryTimeline(t *testing.T) {
+	testStores(t, func(t *testing.T, r *Repository, app string) {
+		// Buckets are UTC hours and days whatever zone reports were received in
+		zone := time.FixedZone("UTC+3", 3*60*60)
+		day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+		seen := []time.Time{
+			day.Add(10*time.Hour + 5*time.Minute).In(zone),
+			day.Add(10*time.Hour + 55*time.Minute),
+			day.Add(23*time.Hour + 30*time.Minute).In(zone),
+			day.Add(25 * time.Hour),
+		}
+		for i, at := range seen {
+			n := models.Node{
+				Application: app,
+				IP:          "192.0.2.20",
+				Port:        2302 + i,
+				Version:     "1.0.0",
+				Type:        "server",
+				FirstSeen:   at,
+				LastSeen:    at,
+			}
+			if err := r.UpsertNode(n); err != nil {
+				t.Fatalf("UpsertNode: %v", err)
+			}
+		}
+
+		tests := []struct {
+			resolution string
+			want       []models.TimeCount
+		}{
+			{ResolutionHour, []models.TimeCount{
+				{Bucket: day.Add(10 * time.Hour), Count: 2},
+				{Bucket: day.Add(23 * time.Hour), Count: 1},
+				{Bucket: day.Add(25 * time.Hour), Count: 1},
+			}},
+			{ResolutionDay, []models.TimeCount{
+				{Bucket: day, Count: 3},
+				{Bucket: day.Add(24 * time.Hour), Count: 1},
+			}},
+		}
+		for _, tt := range tests {
+			s, err := r.GetSummary(NodeFilter{Application: app}, 0, tt.resolution, nil)
+			if err != nil {
+				t.Fatalf("GetSummary: %v", err)
+			}
+			if !reflect.DeepEqual(s.Timeline, tt.want) {
+				t.Errorf("%s timeline = %v, want %v", tt.resolution, s.Timeline, tt.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/semver"
+)
+
+// ImportResult reports how imported nodes were merged into the database.
+type ImportResult struct {
+	// Inserted counts nodes unknown to the database.
+	Inserted int64
+
+	// Updated counts known nodes replaced by a more recently seen imported copy.
+	Updated int64
+
+	// Skipped counts invalid nodes and nodes not seen more recently than the stored copy.
+	Skipped int64
+}
+
+// ExportNodes calls fn for every not deleted node with its tags and custom fields, ordered by ID.
+// Rows are streamed, so the whole database is never held in memory.
+func (r *Repository) ExportNodes(fn func(models.Node) error) error {
+	tags, err := r.allTags()
+	if err != nil {
+		return err
+	}
+	extra, err := r.allExtra()
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT ` + nodeColumns + `
+		FROM nodes
+		WHERE deleted_at IS NULL
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var n models.Node
+		if err := scanNode(rows, &n); err != nil {
+			return err
+		}
+		n.Tags, n.Extra = tags[n.ID], extra[n.ID]
+
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ImportNodes merges nodes into the database in a single transaction.
+// Nodes are matched by their unique identifier (Application, IP, Port), IDs are ignored.
+// Unknown nodes are inserted, known nodes are replaced only if the imported copy was seen later,
+// keeping the earliest first seen time and the largest report count. Tags are merged,
+// custom fields are taken from the imported copy whenever it is inserted or replaces the stored one.
+func (r *Repository) ImportNodes(nodes []models.Node) (ImportResult, error) {
+	var res ImportResult
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return res, err
+	}
+
+	for _, n := range nodes {
+		if n.Application == "" || n.IP == "" || n.Port < 1 || n.Port > 65535 || n.LastSeen.IsZero() {
+			res.Skipped++
+			continue
+		}
+		if n.FirstSeen.IsZero() || n.FirstSeen.After(n.LastSeen) {
+			n.FirstSeen = n.LastSeen
+		}
+		n.Count = max(n.Count, 1)
+		n.Version, _ = semver.Normalize(n.Version)
+
+		var (
+			id                  int64
+			version             string
+			firstSeen, lastSeen time.Time
+			count               int64
+			inserted            bool
+		)
+		err := tx.QueryRow(
+			`SELECT id, version, first_seen, last_seen, count FROM nodes WHERE application = ? AND ip = ? AND port = ?`,
+			n.Application, n.IP, n.Port,
+		).Scan(&id, &version, &firstSeen, &lastSeen, &count)
+
+		switch {
+		case err == sql.ErrNoRows:
+			if err := tx.QueryRow(`
+				INSERT INTO nodes (
+					application, ip, port, version, country_code, type,
+					server_name, map_name, players, max_players, game_version, game_name, server_os,
+					count, first_seen, last_seen, online, `+semverColumns+`
+				)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				RETURNING id
+			`, append([]interface{}{
+				n.Application, n.IP, n.Port, n.Version, n.CountryCode, n.Type,
+				n.ServerName, n.MapName, n.Players, n.MaxPlayers, n.GameVersion, n.GameName, n.ServerOS,
+				n.Count, n.FirstSeen, n.LastSeen, n.GameVersion != "",
+			}, versionArgs(n.Version)...)...,
+			).Scan(&id); err != nil {
+				_ = tx.Rollback()
+				return res, err
+			}
+			inserted = true
+			res.Inserted++
+
+		case err != nil:
+			_ = tx.Rollback()
+			return res, err
+
+		case !n.LastSeen.After(lastSeen):
+			res.Skipped++
+			continue
+
+		default:
+			if firstSeen.Before(n.FirstSeen) {
+				n.FirstSeen = firstSeen
+			}
+			n.Count = max(n.Count, count)
+
+			if _, err := tx.Exec(`
+				UPDATE nodes SET
+					version = ?, country_code = ?, type = ?,
+					server_name = ?, map_name = ?, players = ?, max_players = ?,
+					game_version = ?, game_name = ?, server_os = ?, online = ?,
+					count = ?, first_seen = ?, last_seen = ?, deleted_at = NULL,
+					version_major = ?, version_minor = ?, version_patch = ?, version_pre = ?
+				WHERE id = ?
+			`, append(append([]interface{}{
+				n.Version, n.CountryCode, n.Type,
+				n.ServerName, n.MapName, n.Players, n.MaxPlayers,
+				n.GameVersion, n.GameName, n.ServerOS, n.GameVersion != "",
+				n.Count, n.FirstSeen, n.LastSeen,
+			}, versionArgs(n.Version)...), id)...,
+			); err != nil {
+				_ = tx.Rollback()
+				return res, err
+			}
+			res.Updated++
+		}
+
+		if inserted || version != n.Version {
+			if _, err := tx.Exec(`
+				INSERT INTO version_events (node_id, application, old_version, new_version, changed_at)
+				VALUES (?, ?, ?, ?, ?)
+			`, id, n.Application, version, n.Version, n.LastSeen); err != nil {
+				_ = tx.Rollback()
+				return res, err
+			}
+		}
+
+		if err := replaceExtra(tx, id, n.Extra); err != nil {
+			_ = tx.Rollback()
+			return res, err
+		}
+
+		for _, tag := range n.Tags {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" {
+				continue
+			}
+			if _, err := tx.Exec(
+				`INSERT INTO node_tags (node_id, tag) VALUES (?, ?) ON CONFLICT(node_id, tag) DO NOTHING`, id, tag,
+			); err != nil {
+				_ = tx.Rollback()
+				return res, err
+			}
+		}
+	}
+
+	return res, tx.Commit()
+}
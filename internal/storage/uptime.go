@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// uptimePeriod is a row of node_uptime, a run of reports without a missed heartbeat.
+type uptimePeriod struct {
+	start    time.Time
+	lastSeen time.Time
+	end      time.Time
+}
+
+// grace is how long after end the period may still be extended, one expected heartbeat interval.
+func (p uptimePeriod) grace() time.Time {
+	return p.end.Add(p.end.Sub(p.lastSeen))
+}
+
+// recordUptime extends the latest uptime period of a node with a report, or starts a new one
+// if the report came after a missed heartbeat, within an existing transaction.
+func recordUptime(t *tx, nodeID int64, n models.Node, interval time.Duration) error {
+	var (
+		id int64
+		p  uptimePeriod
+	)
+	err := t.QueryRow(`
+		SELECT id, started_at, last_seen, ended_at FROM node_uptime
+		WHERE node_id = ? ORDER BY started_at DESC LIMIT 1
+	`, nodeID).Scan(&id, &p.start, &p.lastSeen, &p.end)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if err == nil && !n.LastSeen.After(p.grace()) {
+		if !n.LastSeen.After(p.lastSeen) {
+			return nil
+		}
+
+		_, err := t.Exec(`
+			UPDATE node_uptime SET last_seen = ?, ended_at = ? WHERE id = ?
+		`, n.LastSeen, n.LastSeen.Add(interval), id)
+		return err
+	}
+
+	_, err = t.Exec(`
+		INSERT INTO node_uptime (node_id, started_at, last_seen, ended_at) VALUES (?, ?, ?, ?)
+	`, nodeID, n.LastSeen, n.LastSeen, n.LastSeen.Add(interval))
+
+	return err
+}
+
+// GetNodeUptime computes availability of a node between from and to from its uptime periods.
+func (r *Repository) GetNodeUptime(nodeID int64, from, to time.Time) (*models.Uptime, error) {
+	periods, err := r.uptimePeriods(`WHERE u.node_id = ?`, []interface{}{nodeID}, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	uptime := uptimeOf(periods[nodeID], from, to, time.Now())
+	return &uptime, nil
+}
+
+// GetVersionUptime computes availability of tracked nodes between from and to,
+// aggregated per application and the version the nodes currently run,
+// ordered by application and semantic version. If appName is provided (not empty),
+// it restricts results to that application.
+func (r *Repository) GetVersionUptime(appName string, from, to time.Time) ([]models.VersionUptime, error) {
+	where := `WHERE n.deleted_at IS NULL`
+	var args []interface{}
+	if appName != "" {
+		where += " AND n.application = ?"
+		args = append(args, appName)
+	}
+
+	rows, err := r.db.Query(`SELECT n.id, n.application, n.version FROM nodes n `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	type versionKey struct{ app, version string }
+	nodes := make(map[int64]versionKey)
+	for rows.Next() {
+		var (
+			id int64
+			k  versionKey
+		)
+		if err := rows.Scan(&id, &k.app, &k.version); err != nil {
+			continue
+		}
+		nodes[id] = k
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	periods, err := r.uptimePeriods(where, args, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	groups := make(map[versionKey]*models.VersionUptime)
+	for id, ps := range periods {
+		k, ok := nodes[id]
+		if !ok {
+			continue
+		}
+		g, ok := groups[k]
+		if !ok {
+			g = &models.VersionUptime{Application: k.app, Version: k.version}
+			groups[k] = g
+		}
+
+		u := uptimeOf(ps, from, to, now)
+		g.Nodes++
+		g.Availability += u.Availability
+		g.Outages += u.Outages
+		g.LongestOutage = max(g.LongestOutage, u.LongestOutage)
+		if u.Up {
+			g.Up++
+		}
+	}
+
+	result := make([]models.VersionUptime, 0, len(groups))
+	for _, g := range groups {
+		g.Availability /= float64(g.Nodes)
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Application != result[j].Application {
+			return result[i].Application < result[j].Application
+		}
+		return compareVersions(result[i].Version, result[j].Version) < 0
+	})
+
+	return result, nil
+}
+
+// uptimePeriods retrieves uptime periods overlapping from and to of nodes selected by a WHERE clause
+// on nodes (n), keyed by node ID and ordered by start. The latest period of each node is always included,
+// so a node down since before the range is still reported with its outage.
+func (r *Repository) uptimePeriods(where string, args []interface{}, from, to time.Time) (map[int64][]uptimePeriod, error) {
+	args = append(args, from, to)
+	rows, err := r.db.Query(`
+		SELECT u.node_id, u.started_at, u.last_seen, u.ended_at
+		FROM node_uptime u
+		JOIN nodes n ON n.id = u.node_id
+		`+where+` AND (
+			(u.ended_at >= ? AND u.started_at <= ?)
+			OR u.id = (SELECT l.id FROM node_uptime l WHERE l.node_id = u.node_id ORDER BY l.started_at DESC LIMIT 1)
+		)
+		ORDER BY u.node_id, u.started_at
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	periods := make(map[int64][]uptimePeriod)
+	for rows.Next() {
+		var (
+			id int64
+			p  uptimePeriod
+		)
+		if err := rows.Scan(&id, &p.start, &p.lastSeen, &p.end); err != nil {
+			continue
+		}
+		periods[id] = append(periods[id], p)
+	}
+
+	return periods, rows.Err()
+}
+
+// uptimeOf computes availability between from and to from the uptime periods of a node ordered by start.
+// The latest period counts as up until now while its next report is not overdue.
+func uptimeOf(periods []uptimePeriod, from, to, now time.Time) models.Uptime {
+	u := models.Uptime{From: from, To: to}
+	if len(periods) == 0 {
+		return u
+	}
+
+	last := periods[len(periods)-1]
+	u.Tracked = true
+	u.LastSeen = &last.lastSeen
+	u.Up = !now.After(last.grace())
+	if u.Up {
+		u.CurrentStreak = int64(now.Sub(last.start) / time.Second)
+		last.end = now
+		periods[len(periods)-1] = last
+	}
+
+	// Nodes are measured from their first tracked report
+	start, end := from, to
+	if periods[0].start.After(start) {
+		start = periods[0].start
+	}
+	if now.Before(end) {
+		end = now
+	}
+	if !end.After(start) {
+		return u
+	}
+
+	var up time.Duration
+	outage := func(gapStart, gapEnd time.Time) {
+		gapStart, gapEnd = clampTime(gapStart, start, end), clampTime(gapEnd, start, end)
+		if gap := gapEnd.Sub(gapStart); gap > 0 {
+			u.Outages++
+			u.LongestOutage = max(u.LongestOutage, int64(gap/time.Second))
+		}
+	}
+	for i, p := range periods {
+		if d := clampTime(p.end, start, end).Sub(clampTime(p.start, start, end)); d > 0 {
+			up += d
+		}
+		if i > 0 {
+			outage(periods[i-1].end, p.start)
+		}
+	}
+	if !u.Up {
+		outage(last.end, end)
+	}
+
+	u.UpTime = int64(up / time.Second)
+	u.Availability = float64(up) / float64(end.Sub(start))
+
+	return u
+}
+
+// clampTime limits t to the range between lo and hi.
+func clampTime(t, lo, hi time.Time) time.Time {
+	if t.Before(lo) {
+		return lo
+	}
+	if t.After(hi) {
+		return hi
+	}
+
+	return t
+}
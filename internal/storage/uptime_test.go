@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUptimeOf(t *testing.T) {
+	t0 := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return t0.Add(time.Duration(minutes) * time.Minute) }
+
+	// period builds a period of reports every 10 minutes, ending one interval after the last report
+	period := func(start, lastSeen int) uptimePeriod {
+		return uptimePeriod{start: at(start), lastSeen: at(lastSeen), end: at(lastSeen + 10)}
+	}
+
+	tests := []struct {
+		name          string
+		periods       []uptimePeriod
+		from, to, now time.Time
+
+		tracked       bool
+		up            bool
+		availability  float64
+		upTime        int64
+		outages       int64
+		longestOutage int64
+		currentStreak int64
+	}{
+		{
+			name: "untracked",
+			from: at(-60), to: at(120), now: at(60),
+		},
+		{
+			name:    "up within grace",
+			periods: []uptimePeriod{period(0, 50)},
+			from:    at(-60), to: at(120), now: at(70),
+			tracked: true, up: true, availability: 1, upTime: 70 * 60, currentStreak: 70 * 60,
+		},
+		{
+			name:    "down after missed reports",
+			periods: []uptimePeriod{period(0, 50)},
+			from:    at(-60), to: at(120), now: at(100),
+			tracked: true, availability: 0.6, upTime: 60 * 60, outages: 1, longestOutage: 40 * 60,
+		},
+		{
+			name:    "outage between periods",
+			periods: []uptimePeriod{period(0, 20), period(50, 80)},
+			from:    at(0), to: at(120), now: at(95),
+			tracked: true, up: true, availability: 75.0 / 95, upTime: 75 * 60,
+			outages: 1, longestOutage: 20 * 60, currentStreak: 45 * 60,
+		},
+		{
+			name:    "clamped to range",
+			periods: []uptimePeriod{period(0, 20), period(50, 80)},
+			from:    at(40), to: at(60), now: at(180),
+			tracked: true, availability: 0.5, upTime: 10 * 60, outages: 1, longestOutage: 10 * 60,
+		},
+		{
+			name:    "range before first report",
+			periods: []uptimePeriod{period(0, 0)},
+			from:    at(-120), to: at(-60), now: at(5),
+			tracked: true, up: true, currentStreak: 5 * 60,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := uptimeOf(tt.periods, tt.from, tt.to, tt.now)
+
+			if u.Tracked != tt.tracked || u.Up != tt.up {
+				t.Errorf("tracked, up = %v, %v, want %v, %v", u.Tracked, u.Up, tt.tracked, tt.up)
+			}
+			if diff := u.Availability - tt.availability; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("availability = %v, want %v", u.Availability, tt.availability)
+			}
+			if u.UpTime != tt.upTime || u.Outages != tt.outages || u.LongestOutage != tt.longestOutage {
+				t.Errorf("uptime, outages, longest = %d, %d, %d, want %d, %d, %d",
+					u.UpTime, u.Outages, u.LongestOutage, tt.upTime, tt.outages, tt.longestOutage)
+			}
+			if u.CurrentStreak != tt.currentStreak {
+				t.Errorf("current streak = %d, want %d", u.CurrentStreak, tt.currentStreak)
+			}
+			if tt.tracked && (u.LastSeen == nil || !u.LastSeen.Equal(tt.periods[len(tt.periods)-1].lastSeen)) {
+				t.Errorf("last seen = %v, want last report of the latest period", u.LastSeen)
+			}
+		})
+	}
+}
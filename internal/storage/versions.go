@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// GetVersionEvents retrieves version changes recorded between from and to, oldest first.
+// If appName is provided (not empty), it restricts results to that application.
+func (r *Repository) GetVersionEvents(appName string, from, to time.Time) ([]models.VersionEvent, error) {
+	query := `
+		SELECT e.changed_at, e.application, n.ip, e.old_version, e.new_version, e.node_id, n.port
+		FROM version_events e
+		JOIN nodes n ON n.id = e.node_id
+		WHERE e.changed_at >= ? AND e.changed_at <= ?
+	`
+	args := []interface{}{from, to}
+
+	if appName != "" {
+		query += " AND e.application = ?"
+		args = append(args, appName)
+	}
+	query += " ORDER BY e.changed_at ASC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []models.VersionEvent
+	for rows.Next() {
+		var e models.VersionEvent
+		if err := rows.Scan(
+			&e.Time, &e.Application, &e.IP, &e.OldVersion, &e.NewVersion, &e.NodeID, &e.Port,
+		); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
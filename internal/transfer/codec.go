@@ -0,0 +1,309 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/woozymasta/zenit/internal/models"
+)
+
+// csvHeader lists CSV columns in the order they are written.
+// On import columns are matched by name, missing columns are left empty.
+var csvHeader = []string{
+	"id", "application", "type", "ip", "port", "country_code", "version",
+	"server_name", "map_name", "game_version", "game_name", "server_os",
+	"players", "max_players", "count", "first_seen", "last_seen", "tags", "extra",
+}
+
+// csvTagSeparator joins node tags in a single CSV column, it is not allowed in tags.
+// Custom fields are written to the extra column as a JSON object.
+const csvTagSeparator = ";"
+
+// encoder writes nodes one by one, Close finishes the output.
+type encoder interface {
+	Encode(n models.Node) error
+	Close() error
+}
+
+// newEncoder returns an encoder of the format writing to w.
+func newEncoder(w io.Writer, format string) (encoder, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonEncoder{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvEncoder{w: cw}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// jsonEncoder writes nodes as a single JSON array without buffering it.
+type jsonEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonEncoder) Encode(n models.Node) error {
+	prefix := ",\n"
+	if e.count == 0 {
+		prefix = "[\n"
+	}
+	e.count++
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(e.w, prefix); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	end := "\n]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// ndjsonEncoder writes one JSON node per line.
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(n models.Node) error { return e.enc.Encode(n) }
+
+func (e *ndjsonEncoder) Close() error { return nil }
+
+// csvEncoder writes one node per CSV record.
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Encode(n models.Node) error {
+	var extra string
+	if len(n.Extra) > 0 {
+		data, err := json.Marshal(n.Extra)
+		if err != nil {
+			return err
+		}
+		extra = string(data)
+	}
+
+	return e.w.Write([]string{
+		strconv.FormatInt(n.ID, 10), n.Application, n.Type, n.IP, strconv.Itoa(n.Port), n.CountryCode, n.Version,
+		n.ServerName, n.MapName, n.GameVersion, n.GameName, n.ServerOS,
+		strconv.Itoa(int(n.Players)), strconv.Itoa(int(n.MaxPlayers)), strconv.FormatInt(n.Count, 10),
+		n.FirstSeen.UTC().Format(time.RFC3339Nano), n.LastSeen.UTC().Format(time.RFC3339Nano),
+		strings.Join(n.Tags, csvTagSeparator), extra,
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// decode reads nodes of the format from r and calls fn for each of them.
+func decode(r io.Reader, format string, fn func(models.Node) error) error {
+	switch format {
+	case FormatJSON:
+		return decodeJSON(r, fn)
+	case FormatNDJSON:
+		return decodeNDJSON(r, fn)
+	case FormatCSV:
+		return decodeCSV(r, fn)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// decodeJSON reads a JSON array of nodes element by element.
+func decodeJSON(r io.Reader, fn func(models.Node) error) error {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("read json: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return errors.New("read json: expected an array of nodes")
+	}
+
+	for i := 1; dec.More(); i++ {
+		var n models.Node
+		if err := dec.Decode(&n); err != nil {
+			return fmt.Errorf("read json node %d: %w", i, err)
+		}
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("read json: %w", err)
+	}
+
+	return nil
+}
+
+// decodeNDJSON reads one JSON node per line, blank lines are ignored.
+func decodeNDJSON(r io.Reader, fn func(models.Node) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+
+	for line := 1; sc.Scan(); line++ {
+		data := strings.TrimSpace(sc.Text())
+		if data == "" {
+			continue
+		}
+
+		var n models.Node
+		if err := json.Unmarshal([]byte(data), &n); err != nil {
+			return fmt.Errorf("read ndjson line %d: %w", line, err)
+		}
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+
+	return sc.Err()
+}
+
+// decodeCSV reads nodes from CSV with a header row, columns are matched by csvHeader names.
+func decodeCSV(r io.Reader, fn func(models.Node) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"application", "ip", "port", "last_seen"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("read csv header: missing column %q", required)
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read csv: %w", err)
+		}
+
+		n, err := csvNode(record, columns)
+		if err != nil {
+			return fmt.Errorf("read csv line %d: %w", line, err)
+		}
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+}
+
+// csvNode converts a CSV record into a node.
+func csvNode(record []string, columns map[string]int) (models.Node, error) {
+	get := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	n := models.Node{
+		Application: get("application"),
+		Type:        get("type"),
+		IP:          get("ip"),
+		CountryCode: get("country_code"),
+		Version:     get("version"),
+		ServerName:  get("server_name"),
+		MapName:     get("map_name"),
+		GameVersion: get("game_version"),
+		GameName:    get("game_name"),
+		ServerOS:    get("server_os"),
+	}
+
+	var err error
+	if n.Port, err = atoi(get("port")); err != nil {
+		return n, fmt.Errorf("port: %w", err)
+	}
+	if n.Count, err = strconv.ParseInt(orZero(get("count")), 10, 64); err != nil {
+		return n, fmt.Errorf("count: %w", err)
+	}
+
+	players, err := strconv.ParseUint(orZero(get("players")), 10, 8)
+	if err != nil {
+		return n, fmt.Errorf("players: %w", err)
+	}
+	maxPlayers, err := strconv.ParseUint(orZero(get("max_players")), 10, 8)
+	if err != nil {
+		return n, fmt.Errorf("max_players: %w", err)
+	}
+	n.Players, n.MaxPlayers = byte(players), byte(maxPlayers)
+
+	if n.FirstSeen, err = parseTime(get("first_seen")); err != nil {
+		return n, fmt.Errorf("first_seen: %w", err)
+	}
+	if n.LastSeen, err = parseTime(get("last_seen")); err != nil {
+		return n, fmt.Errorf("last_seen: %w", err)
+	}
+
+	if tags := get("tags"); tags != "" {
+		n.Tags = strings.Split(tags, csvTagSeparator)
+	}
+	if extra := get("extra"); extra != "" {
+		if err := json.Unmarshal([]byte(extra), &n.Extra); err != nil {
+			return n, fmt.Errorf("extra: %w", err)
+		}
+	}
+
+	return n, nil
+}
+
+// atoi parses an integer, an empty string is zero.
+func atoi(s string) (int, error) {
+	return strconv.Atoi(orZero(s))
+}
+
+// orZero replaces an empty string with "0".
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+// parseTime parses an RFC3339 timestamp, an empty string is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,119 @@
+// Package transfer exports and imports the node database as JSON, NDJSON or CSV.
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/woozymasta/zenit/internal/models"
+	"github.com/woozymasta/zenit/internal/storage"
+)
+
+// Supported file formats.
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// importBatchSize is the number of nodes merged in a single transaction on import.
+const importBatchSize = 500
+
+// ValidFormat reports whether format is a supported file format.
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatJSON, FormatNDJSON, FormatCSV:
+		return true
+	}
+
+	return false
+}
+
+// FormatFromPath detects the file format from the extension of path
+// (.json, .ndjson or .jsonl, .csv).
+func FormatFromPath(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return FormatJSON, nil
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	case ".csv":
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("unknown file format %q, expected .json, .ndjson or .csv", ext)
+	}
+}
+
+// ContentType returns the MIME type of the format.
+func ContentType(format string) string {
+	switch format {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// Export writes all not deleted nodes of the store to w in the given format
+// and returns the number of written nodes.
+func Export(w io.Writer, format string, store storage.Store) (int64, error) {
+	enc, err := newEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := store.ExportNodes(func(n models.Node) error {
+		count++
+		return enc.Encode(n)
+	}); err != nil {
+		return count, err
+	}
+
+	return count, enc.Close()
+}
+
+// Import reads nodes in the given format from r and merges them into the store in batches.
+// The result accumulates all batches merged before an error occurred.
+func Import(r io.Reader, format string, store storage.Store) (storage.ImportResult, error) {
+	var (
+		total storage.ImportResult
+		batch = make([]models.Node, 0, importBatchSize)
+	)
+
+	flush := func() error {
+		res, err := store.ImportNodes(batch)
+		if err != nil {
+			return err
+		}
+
+		total.Inserted += res.Inserted
+		total.Updated += res.Updated
+		total.Skipped += res.Skipped
+		batch = batch[:0]
+
+		return nil
+	}
+
+	if err := decode(r, format, func(n models.Node) error {
+		batch = append(batch, n)
+		if len(batch) < importBatchSize {
+			return nil
+		}
+		return flush()
+	}); err != nil {
+		return total, err
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}